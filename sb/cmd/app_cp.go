@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+var cpCmd = &cobra.Command{
+	Use:   "cp SRC DEST",
+	Short: "Copy files to and from an app pod",
+	Long: `Copies files and directories between the local machine and an app pod. The
+remote side is written as APP:PATH, where APP is the app name or UUID:
+
+  sb-cli apps cp ./fixtures myapp:/workspace/fixtures
+  sb-cli apps cp myapp:/workspace/reports ./reports
+
+With --volume, the remote path is relative to where that volume is mounted:
+
+  sb-cli apps cp --volume uploads myapp:exports/today.csv .
+
+Like cp, copying into an existing directory keeps the source name. The pod
+needs tar installed.`,
+	Args: cobra.ExactArgs(2),
+	Run:  appCp,
+}
+
+var (
+	cpVolume    string
+	cpContainer string
+)
+
+func appCp(cmd *cobra.Command, args []string) {
+	srcApp, srcPath, srcRemote := parseCpSpec(args[0])
+	destApp, destPath, destRemote := parseCpSpec(args[1])
+	if srcRemote == destRemote {
+		fmt.Fprintln(os.Stderr, "Error: exactly one of SRC and DEST must be APP:PATH")
+		os.Exit(1)
+	}
+	appName, remotePath := srcApp, srcPath
+	if destRemote {
+		appName, remotePath = destApp, destPath
+	}
+
+	app, err := resolveApp(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	remotePath, err = cpRemotePath(app.UUID, remotePath, cpVolume)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cluster, err := connectApp(app.UUID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to the cluster: %v\n", err)
+		os.Exit(1)
+	}
+	ctx := context.Background()
+	pod, err := cluster.selectPod(ctx, app.UUID, "", logProcess{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	container, err := podContainer(pod, cpContainer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	copier := &podCopier{cluster: cluster, pod: pod.Name, container: container}
+	if destRemote {
+		err = copier.upload(ctx, srcPath, remotePath)
+	} else {
+		err = copier.download(ctx, remotePath, destPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseCpSpec splits APP:PATH. Anything else, including Windows drive
+// letters and paths like ./a:b, is a local path.
+func parseCpSpec(spec string) (string, string, bool) {
+	app, remotePath, found := strings.Cut(spec, ":")
+	if !found || len(app) < 2 || strings.ContainsAny(app, `/\`) {
+		return "", spec, false
+	}
+	return app, remotePath, true
+}
+
+// cpRemotePath resolves remotePath against the mount path of volume, if set.
+func cpRemotePath(appUUID, remotePath, volume string) (string, error) {
+	if volume == "" {
+		if remotePath == "" {
+			return "", fmt.Errorf("the remote path must not be empty")
+		}
+		return remotePath, nil
+	}
+	volumes, err := fetchVolume(appUUID)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, v := range volumes {
+		if v.Name == volume {
+			return path.Join(v.MountPath, remotePath), nil
+		}
+		names = append(names, v.Name)
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("the app has no volumes")
+	}
+	return "", fmt.Errorf("volume %q not found, the app has: %s", volume, strings.Join(names, ", "))
+}
+
+// podCopier moves files in and out of a container by streaming tar archives
+// over exec.
+type podCopier struct {
+	cluster   *appCluster
+	pod       string
+	container string
+}
+
+// run execs command, failing with its stderr output if it exits non-zero.
+func (c *podCopier) run(ctx context.Context, command []string, stdin io.Reader, stdout io.Writer) error {
+	var stderr bytes.Buffer
+	err := c.cluster.exec(ctx, execOptions{
+		Pod:       c.pod,
+		Container: c.container,
+		Command:   command,
+		Stdin:     stdin,
+		Stdout:    stdout,
+		Stderr:    &stderr,
+	})
+	if err != nil && stderr.Len() > 0 {
+		return fmt.Errorf("%s: %s", command[0], strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// isDir reports whether remotePath is a directory in the container.
+func (c *podCopier) isDir(ctx context.Context, remotePath string) (bool, error) {
+	err := c.cluster.exec(ctx, execOptions{
+		Pod:       c.pod,
+		Container: c.container,
+		Command:   []string{"test", "-d", remotePath},
+		Stdout:    io.Discard,
+	})
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *podCopier) upload(ctx context.Context, src, dest string) error {
+	total, err := localSize(src)
+	if err != nil {
+		return err
+	}
+
+	// Copying into an existing directory keeps the source name, otherwise
+	// the source is renamed to dest.
+	isDir, err := c.isDir(ctx, dest)
+	if err != nil {
+		return err
+	}
+	dir, name := path.Dir(dest), path.Base(dest)
+	if isDir {
+		dir, name = dest, filepath.Base(src)
+	}
+
+	progress := &copyProgress{total: total}
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := writeTar(tw, src, name, progress)
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+		written <- err
+	}()
+
+	err = c.run(ctx, []string{"tar", "xmf", "-", "-C", dir}, pr, io.Discard)
+	// Unblock the writer if the remote tar stopped reading early.
+	pr.Close()
+	if writeErr := <-written; err == nil && writeErr != nil {
+		err = writeErr
+	}
+	progress.finish()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Copied %d files (%s) to %s:%s\n", progress.files, formatBytes(progress.done), c.pod, path.Join(dir, name))
+	return nil
+}
+
+func (c *podCopier) download(ctx context.Context, src, dest string) error {
+	src = path.Clean(src)
+	if src == "/" || src == "." {
+		return fmt.Errorf("copy a file or directory below %s, not %s itself", src, src)
+	}
+	base := path.Base(src)
+	root := dest
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		root = filepath.Join(dest, base)
+	}
+
+	progress := &copyProgress{}
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := c.run(ctx, []string{"tar", "cf", "-", "-C", path.Dir(src), base}, nil, pw)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	err := extractTar(tar.NewReader(pr), base, root, progress)
+	// Unblock the remote tar if extracting stopped early.
+	pr.CloseWithError(err)
+	if runErr := <-done; err == nil {
+		err = runErr
+	}
+	progress.finish()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Copied %d files (%s) to %s\n", progress.files, formatBytes(progress.done), root)
+	return nil
+}
+
+// localSize returns the total size of the regular files under src.
+func localSize(src string) (int64, error) {
+	var total int64
+	err := filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// writeTar writes src to tw, naming its root entry name.
+func writeTar(tw *tar.Writer, src, name string, progress *copyProgress) error {
+	return filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		} else if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(name, filepath.ToSlash(rel))
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(tw, &progressCounter{r: f, progress: progress}); err != nil {
+			return err
+		}
+		progress.files++
+		return nil
+	})
+}
+
+// extractTar writes the entries of tr below base to root. Entries that would
+// end up outside root are refused, and links are skipped.
+func extractTar(tr *tar.Reader, base, root string, progress *copyProgress) error {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("refusing to extract %s outside of %s", header.Name, root)
+		}
+		var rel string
+		switch {
+		case name == base:
+		case strings.HasPrefix(name, base+"/"):
+			rel = strings.TrimPrefix(name, base+"/")
+		default:
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(rel))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := extractFile(tr, target, header.FileInfo().Mode().Perm(), progress); err != nil {
+				return err
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "\rSkipping %s, only files and directories are copied\n", header.Name)
+		}
+	}
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode, progress *copyProgress) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, &progressCounter{r: r, progress: progress}); err != nil {
+		f.Close()
+		return err
+	}
+	progress.files++
+	return f.Close()
+}
+
+// copyProgress prints the amount of data copied to stderr. total is zero
+// when it is not known up front.
+type copyProgress struct {
+	total   int64
+	done    int64
+	files   int
+	printed time.Time
+}
+
+func (p *copyProgress) add(n int) {
+	p.done += int64(n)
+	if time.Since(p.printed) >= 100*time.Millisecond {
+		p.print()
+	}
+}
+
+func (p *copyProgress) print() {
+	p.printed = time.Now()
+	if p.total > 0 {
+		fmt.Fprintf(os.Stderr, "\rCopying %s / %s (%d%%)", formatBytes(p.done), formatBytes(p.total), p.done*100/p.total)
+	} else {
+		fmt.Fprintf(os.Stderr, "\rCopying %s", formatBytes(p.done))
+	}
+}
+
+func (p *copyProgress) finish() {
+	if !p.printed.IsZero() {
+		p.print()
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// progressCounter reports bytes read from r to progress.
+type progressCounter struct {
+	r        io.Reader
+	progress *copyProgress
+}
+
+func (c *progressCounter) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	if n > 0 {
+		c.progress.add(n)
+	}
+	return n, err
+}
+
+func init() {
+	cpCmd.Flags().StringVar(&cpVolume, "volume", "", "Resolve the remote path relative to this volume's mount path")
+	cpCmd.Flags().StringVarP(&cpContainer, "container", "c", "", "Container to copy to or from (default the app container)")
+	appsCmd.AddCommand(cpCmd)
+}
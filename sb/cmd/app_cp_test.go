@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseCpSpec(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantApp    string
+		wantPath   string
+		wantRemote bool
+	}{
+		{"myapp:/workspace/file", "myapp", "/workspace/file", true},
+		{"myapp:relative/file", "myapp", "relative/file", true},
+		{"myapp:path:with:colons", "myapp", "path:with:colons", true},
+		{"app:", "app", "", true},
+		{`C:\x`, "", `C:\x`, false},
+		{"C:/x", "", "C:/x", false},
+		{"c:", "", "c:", false},
+		{"./a:b", "", "./a:b", false},
+		{"dir/a:b", "", "dir/a:b", false},
+		{`dir\a:b`, "", `dir\a:b`, false},
+		{"/abs/a:b", "", "/abs/a:b", false},
+		{"local/file", "", "local/file", false},
+		{".", "", ".", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			app, remotePath, remote := parseCpSpec(tt.spec)
+			if app != tt.wantApp || remotePath != tt.wantPath || remote != tt.wantRemote {
+				t.Errorf("parseCpSpec(%q) = %q, %q, %v, want %q, %q, %v", tt.spec, app, remotePath, remote, tt.wantApp, tt.wantPath, tt.wantRemote)
+			}
+		})
+	}
+}
+
+func TestCpRemotePathEmpty(t *testing.T) {
+	if _, err := cpRemotePath("app-uuid", "", ""); err == nil {
+		t.Error("expected an error for an empty remote path")
+	}
+}
+
+// tarEntry is one entry of a test archive; typeflag defaults to a regular
+// file.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	body     string
+	link     string
+}
+
+func tarArchive(t *testing.T, entries ...tarEntry) *tar.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Linkname: entry.link, Mode: 0644}
+		switch entry.typeflag {
+		case 0:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(entry.body))
+		case tar.TypeDir:
+			header.Mode = 0755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return tar.NewReader(&buf)
+}
+
+// listFiles returns the slash separated paths below dir, directories with a
+// trailing slash.
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || file == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			rel += "/"
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestExtractTar(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		entries []tarEntry
+		// want lists what ends up next to the extraction root "out".
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "directory",
+			base: "reports",
+			entries: []tarEntry{
+				{name: "reports/", typeflag: tar.TypeDir},
+				{name: "reports/a.txt", body: "a"},
+				{name: "reports/sub/b.txt", body: "b"},
+			},
+			want: []string{"out/", "out/a.txt", "out/sub/", "out/sub/b.txt"},
+		},
+		{
+			name:    "single file",
+			base:    "today.csv",
+			entries: []tarEntry{{name: "today.csv", body: "csv"}},
+			want:    []string{"out"},
+		},
+		{
+			name: "entries outside base are skipped",
+			base: "reports",
+			entries: []tarEntry{
+				{name: "reports/a.txt", body: "a"},
+				{name: "other/b.txt", body: "b"},
+				{name: "reports-old/c.txt", body: "c"},
+			},
+			want: []string{"out/", "out/a.txt"},
+		},
+		{
+			name: "cleaned names inside base",
+			base: "reports",
+			entries: []tarEntry{
+				{name: "reports/sub/../a.txt", body: "a"},
+				{name: "./reports/b.txt", body: "b"},
+			},
+			want: []string{"out/", "out/a.txt", "out/b.txt"},
+		},
+		{
+			name:    "parent directory",
+			base:    "reports",
+			entries: []tarEntry{{name: "../evil.txt", body: "evil"}},
+			want:    nil,
+			wantErr: "refusing to extract ../evil.txt",
+		},
+		{
+			name:    "parent directory after cleaning",
+			base:    "reports",
+			entries: []tarEntry{{name: "reports/../../evil.txt", body: "evil"}},
+			want:    nil,
+			wantErr: "refusing to extract reports/../../evil.txt",
+		},
+		{
+			name:    "bare parent directory",
+			base:    "reports",
+			entries: []tarEntry{{name: "..", typeflag: tar.TypeDir}},
+			want:    nil,
+			wantErr: "refusing to extract ..",
+		},
+		{
+			name: "absolute name",
+			base: "reports",
+			entries: []tarEntry{
+				{name: "reports/a.txt", body: "a"},
+				{name: "/tmp/evil.txt", body: "evil"},
+			},
+			want:    []string{"out/", "out/a.txt"},
+			wantErr: "refusing to extract /tmp/evil.txt",
+		},
+		{
+			name: "symlink is skipped",
+			base: "reports",
+			entries: []tarEntry{
+				{name: "reports/a.txt", body: "a"},
+				{name: "reports/passwd", typeflag: tar.TypeSymlink, link: "/etc/passwd"},
+				{name: "reports/up", typeflag: tar.TypeSymlink, link: "../.."},
+			},
+			want: []string{"out/", "out/a.txt"},
+		},
+		{
+			name: "hardlink is skipped",
+			base: "reports",
+			entries: []tarEntry{
+				{name: "reports/a.txt", body: "a"},
+				{name: "reports/hard", typeflag: tar.TypeLink, link: "/etc/passwd"},
+			},
+			want: []string{"out/", "out/a.txt"},
+		},
+		{
+			name: "write through skipped symlink",
+			base: "reports",
+			entries: []tarEntry{
+				{name: "reports/up", typeflag: tar.TypeSymlink, link: ".."},
+				{name: "reports/up/evil.txt", body: "evil"},
+			},
+			want: []string{"out/", "out/up/", "out/up/evil.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			root := filepath.Join(dir, "out")
+
+			err := extractTar(tarArchive(t, tt.entries...), tt.base, root, &copyProgress{})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+			if got := listFiles(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extracted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -73,10 +73,10 @@ func appCreate(cmd *cobra.Command, args []string) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
 
 	defer resp.Body.Close()
@@ -16,7 +16,6 @@ var appDeleteCmd = &cobra.Command{
 }
 
 func appDelete(cmd *cobra.Command, args []string) {
-	client := &http.Client{}
 
 	sbUrl, token, _, err := getContext()
 	if err != nil {
@@ -51,9 +50,10 @@ func appDelete(cmd *cobra.Command, args []string) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
 
 	defer resp.Body.Close()
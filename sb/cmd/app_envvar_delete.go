@@ -78,10 +78,10 @@ func appEnvVarDelete(cmd *cobra.Command, args []string) {
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
 	// Send the request using the default client
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
 
 	defer resp.Body.Close() // Ensure the response body is closed
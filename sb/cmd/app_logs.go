@@ -3,169 +3,422 @@ package cmd
 import (
 	"bufio"
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 var logsCmd = &cobra.Command{
-	Use:     "logs",
-	Short:   "A brief description of your command",
+	Use:   "logs",
+	Short: "Show the logs of an app's pods",
+	Long: `Prints the logs of every pod and container of an app, each line prefixed with
+its pod and container. With --follow, all containers are streamed at once and
+pods started later are picked up automatically.
+
+With --output-dir, each pod/container is written to its own file instead,
+rotated once it reaches --max-size megabytes.`,
 	Aliases: []string{"log"},
 	Run:     appLogs,
 }
 
-var tail bool
+var (
+	logsApp       string
+	logsProcess   string
+	followLogs    bool
+	logsSince     time.Duration
+	logsSinceTime string
+	logsTail      int64
+	logsContainer string
+	logsTimestamp bool
+	logsPrevious  bool
+	logsGrep      string
+	logsExclude   string
+	logsFormat    string
+	logsFields    []string
+	logsLevel     string
+	logsOutputDir string
+	logsMaxSize   int64
+	logsGzip      bool
+)
+
+// logLine is one line of output from a container.
+type logLine struct {
+	Pod       string
+	Container string
+	Text      string
+}
 
 func appLogs(cmd *cobra.Command, args []string) {
-	apps, err := fetchApps()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error fetching apps: %v\n", err)
-		return
+	printer := newLogPrinter(os.Stdout)
+	if err := printer.configure(logsFormat, logsFields, logsLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	app := selectApp(apps)
-
-	// API call
-	sbUrl, token, _, err := getContext()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
-		return
+	streamer := &logStreamer{
+		container: logsContainer,
+		emit:      printer.print,
 	}
-	fullUrl := fmt.Sprintf("%s/apps/%s/shell-info/", sbUrl, app.UUID)
-
-	req, err := http.NewRequest("GET", fullUrl, nil)
-	if err != nil {
-		fmt.Println(err)
+	if logsOutputDir == "" && logsGzip {
+		fmt.Fprintln(os.Stderr, "Error: --gzip requires --output-dir")
+		os.Exit(1)
+	}
+	var exporter *logExporter
+	if logsOutputDir != "" {
+		var err error
+		exporter, err = newLogExporter(printer, logsOutputDir, logsMaxSize*1024*1024, logsGzip)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		streamer.emit = exporter.write
+	}
+	if err := streamer.setOptions(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	app, err := resolveApp(logsApp)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	defer resp.Body.Close()
-	var shellInfo ShellInfo
-	if err := json.NewDecoder(resp.Body).Decode(&shellInfo); err != nil {
-		fmt.Printf("Unable to decode podinfo from response: %v\n", err)
+	if err := streamer.process.validate(app.UUID); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	streamer.selector = fmt.Sprintf("appUuid=%s", app.UUID)
 
-	decodedKubeConfig, err := base64.StdEncoding.DecodeString(shellInfo.KubeConfig)
+	clientset, namespace, err := appClientset(app.UUID)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to decode kubeconfig: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error connecting to the cluster: %v\n", err)
 		os.Exit(1)
 	}
 
-	label := fmt.Sprintf("appUuid=%s", app.UUID)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	streamer.clientset = clientset
+	streamer.namespace = namespace
 
-	if err = streamLogsFromPods(label, shellInfo.Namespace, string(decodedKubeConfig), tail); err != nil {
+	if followLogs {
+		err = streamer.follow(ctx)
+	} else {
+		err = streamer.snapshot(ctx)
+	}
+	// Streams have ended by now, including after Ctrl-C, so files are
+	// complete.
+	if exporter != nil {
+		if closeErr := exporter.close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to stream logs: %v\n", err)
 		os.Exit(1)
 	}
+}
+
+// logStreamer reads the logs of all pods matching selector.
+type logStreamer struct {
+	clientset kubernetes.Interface
+	namespace string
+	selector  string
+	emit      func(logLine)
 
+	// Only stream this container, if set.
+	container string
+	process   logProcess
+	// Options shared by every log request; Container and Follow are set per
+	// request.
+	options v1.PodLogOptions
+	// Client-side line filters.
+	grep    *regexp.Regexp
+	exclude *regexp.Regexp
+
+	mu sync.Mutex
+	// Streams in progress, keyed by pod/container.
+	active map[string]context.CancelFunc
+	// When a stream last ended, so a restarted container resumes from there.
+	ended map[string]time.Time
+	wg    sync.WaitGroup
 }
 
-func streamLogsFromPods(label, namespace, kubeconfig string, stream bool) error {
-	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
-	if err != nil {
-		return err
+// setOptions maps the log flags onto the streamer.
+func (s *logStreamer) setOptions() error {
+	if logsPrevious && followLogs {
+		return fmt.Errorf("--previous cannot be used with --follow")
+	}
+	if logsSince != 0 && logsSinceTime != "" {
+		return fmt.Errorf("only one of --since and --since-time can be used")
+	}
+
+	if logsSince != 0 {
+		seconds := int64(logsSince.Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		s.options.SinceSeconds = &seconds
+	}
+	if logsSinceTime != "" {
+		t, err := time.Parse(time.RFC3339, logsSinceTime)
+		if err != nil {
+			return fmt.Errorf("--since-time must be an RFC3339 timestamp: %v", err)
+		}
+		since := metav1.NewTime(t)
+		s.options.SinceTime = &since
+	}
+	switch {
+	case logsTail >= 0:
+		s.options.TailLines = int64Ptr(logsTail)
+	case !followLogs && logsSince == 0 && logsSinceTime == "":
+		// Without any bounds, only show recent lines of each container.
+		s.options.TailLines = int64Ptr(100)
 	}
+	s.options.Timestamps = logsTimestamp
+	s.options.Previous = logsPrevious
 
-	clientset, err := kubernetes.NewForConfig(config)
+	process, err := parseLogProcess(logsProcess)
 	if err != nil {
 		return err
 	}
+	s.process = process
 
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: label,
-	})
+	if logsGrep != "" {
+		if s.grep, err = regexp.Compile(logsGrep); err != nil {
+			return fmt.Errorf("invalid --grep: %v", err)
+		}
+	}
+	if logsExclude != "" {
+		if s.exclude, err = regexp.Compile(logsExclude); err != nil {
+			return fmt.Errorf("invalid --exclude: %v", err)
+		}
+	}
+	return nil
+}
+
+// snapshot prints the logs of every container of every pod.
+func (s *logStreamer) snapshot(ctx context.Context) error {
+	pods, err := s.clientset.CoreV1().Pods(s.namespace).List(ctx, metav1.ListOptions{LabelSelector: s.selector})
 	if err != nil {
 		return err
 	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
 
 	for _, pod := range pods.Items {
-		if stream {
-			fmt.Printf("Streaming logs for pod %s\n", pod.Name)
-			err := streamPodLogs(clientset, pod, namespace)
-			if err != nil {
-				fmt.Printf("Error streaming logs for pod %s: %v\n", pod.Name, err)
+		for _, status := range pod.Status.InitContainerStatuses {
+			// Init containers that never started have no logs.
+			if status.State.Waiting == nil && s.wants(&pod, status.Name, true) {
+				s.printLogs(ctx, pod.Name, status.Name)
 			}
-		} else {
-			fmt.Printf("Getting last 100 lines of logs for pod %s\n", pod.Name)
-			err := getLastPodLogs(clientset, pod, namespace)
-			if err != nil {
-				fmt.Printf("Error getting logs for pod %s: %v\n", pod.Name, err)
+		}
+		for _, container := range pod.Spec.Containers {
+			if s.wants(&pod, container.Name, false) {
+				s.printLogs(ctx, pod.Name, container.Name)
 			}
 		}
 	}
-
 	return nil
 }
 
-func streamPodLogs(clientset *kubernetes.Clientset, pod v1.Pod, namespace string) error {
-	req := clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, &v1.PodLogOptions{
-		Follow: true,
-	})
-
-	podLogs, err := req.Stream(context.TODO())
-	if err != nil {
-		return err
+func (s *logStreamer) printLogs(ctx context.Context, podName, container string) {
+	opts := s.options
+	opts.Container = container
+	req := s.clientset.CoreV1().Pods(s.namespace).GetLogs(podName, &opts)
+	if err := s.copyLines(ctx, req.Stream, podName, container); err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting logs for %s/%s: %v\n", podName, container, err)
 	}
-	defer podLogs.Close()
+}
 
-	scanner := bufio.NewScanner(podLogs)
-	for scanner.Scan() {
-		fmt.Println(scanner.Text())
+// wants reports whether a container of pod was selected with --container
+// and --process.
+func (s *logStreamer) wants(pod *v1.Pod, container string, init bool) bool {
+	if s.container != "" && container != s.container {
+		return false
 	}
+	return s.process.matches(pod, container, init)
+}
 
-	if err := scanner.Err(); err != nil {
-		return err
+// follow streams all running containers concurrently until ctx is done,
+// watching the selector for pods that start or terminate.
+func (s *logStreamer) follow(ctx context.Context) error {
+	s.active = map[string]context.CancelFunc{}
+	s.ended = map[string]time.Time{}
+	defer s.wg.Wait()
+
+	pods := s.clientset.CoreV1().Pods(s.namespace)
+	for {
+		list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: s.selector})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for i := range list.Items {
+			s.sync(ctx, &list.Items[i])
+		}
+
+		watcher, err := pods.Watch(ctx, metav1.ListOptions{
+			LabelSelector:   s.selector,
+			ResourceVersion: list.ResourceVersion,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		s.handleEvents(ctx, watcher)
+		watcher.Stop()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		// The watch timed out on the server side; list again and resume.
 	}
+}
 
-	return nil
+// handleEvents applies pod events until the watch ends or ctx is done.
+func (s *logStreamer) handleEvents(ctx context.Context, watcher watch.Interface) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+			pod, isPod := event.Object.(*v1.Pod)
+			if !isPod {
+				continue
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				s.sync(ctx, pod)
+			case watch.Deleted:
+				s.drop(pod.Name)
+			}
+		}
+	}
 }
 
-func getLastPodLogs(clientset *kubernetes.Clientset, pod v1.Pod, namespace string) error {
-	req := clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, &v1.PodLogOptions{
-		TailLines: int64Ptr(100),
-	})
+// sync starts streams for the running containers of pod and drops pods that
+// have terminated.
+func (s *logStreamer) sync(ctx context.Context, pod *v1.Pod) {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		s.drop(pod.Name)
+		return
+	}
 
-	podLogs, err := req.Stream(context.TODO())
-	if err != nil {
-		return err
+	initContainers := len(pod.Status.InitContainerStatuses)
+	statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+	for i, status := range statuses {
+		if status.State.Running == nil || !s.wants(pod, status.Name, i < initContainers) {
+			continue
+		}
+		key := pod.Name + "/" + status.Name
+
+		s.mu.Lock()
+		if _, streaming := s.active[key]; streaming {
+			s.mu.Unlock()
+			continue
+		}
+		streamCtx, cancel := context.WithCancel(ctx)
+		s.active[key] = cancel
+		opts := s.options
+		opts.Container = status.Name
+		opts.Follow = true
+		if ended, ok := s.ended[key]; ok {
+			since := metav1.NewTime(ended)
+			opts.SinceTime = &since
+			opts.SinceSeconds = nil
+			opts.TailLines = nil
+		}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func(podName, container string) {
+			defer s.wg.Done()
+			req := s.clientset.CoreV1().Pods(s.namespace).GetLogs(podName, &opts)
+			err := s.copyLines(streamCtx, req.Stream, podName, container)
+			if err != nil && streamCtx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "Error streaming logs for %s/%s: %v\n", podName, container, err)
+			}
+
+			s.mu.Lock()
+			delete(s.active, key)
+			s.ended[key] = time.Now()
+			s.mu.Unlock()
+			cancel()
+		}(pod.Name, status.Name)
 	}
-	defer podLogs.Close()
+}
 
-	scanner := bufio.NewScanner(podLogs)
-	for scanner.Scan() {
-		fmt.Println(scanner.Text())
+// drop stops streaming every container of a pod.
+func (s *logStreamer) drop(podName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, cancel := range s.active {
+		if strings.HasPrefix(key, podName+"/") {
+			cancel()
+			delete(s.active, key)
+		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
+func (s *logStreamer) copyLines(ctx context.Context, open func(context.Context) (io.ReadCloser, error), podName, container string) error {
+	stream, err := open(ctx)
+	if err != nil {
 		return err
 	}
+	defer stream.Close()
 
-	return nil
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if (s.grep != nil && !s.grep.MatchString(line)) || (s.exclude != nil && s.exclude.MatchString(line)) {
+			continue
+		}
+		s.emit(logLine{Pod: podName, Container: container, Text: line})
+	}
+	return scanner.Err()
 }
 
 func int64Ptr(i int64) *int64 {
 	return &i
 }
+
 func init() {
 	appsCmd.AddCommand(logsCmd)
-	logsCmd.Flags().BoolVarP(&tail, "follow", "f", false, "Follow the pod logs")
+	logsCmd.Flags().StringVar(&logsApp, "app", "", "App name or UUID")
+	logsCmd.Flags().BoolVarP(&followLogs, "follow", "f", false, "Follow the pod logs")
+	logsCmd.Flags().DurationVar(&logsSince, "since", 0, "Only show logs newer than a relative duration like 10m or 2h")
+	logsCmd.Flags().StringVar(&logsSinceTime, "since-time", "", "Only show logs after an RFC3339 timestamp")
+	logsCmd.Flags().Int64Var(&logsTail, "tail", -1, "Lines of recent logs to show per container (default 100, or all with --follow or --since)")
+	logsCmd.Flags().StringVar(&logsProcess, "process", processAll, "Process to show: web, worker:NAME, init:NAME or all")
+	logsCmd.Flags().StringVarP(&logsContainer, "container", "c", "", "Only show logs of this container")
+	logsCmd.Flags().BoolVar(&logsTimestamp, "timestamps", false, "Include timestamps on each line")
+	logsCmd.Flags().BoolVarP(&logsPrevious, "previous", "p", false, "Show logs of the previous, crashed instance of each container")
+	logsCmd.Flags().StringVar(&logsGrep, "grep", "", "Only show lines matching this regular expression")
+	logsCmd.Flags().StringVar(&logsExclude, "exclude", "", "Hide lines matching this regular expression")
+	logsCmd.Flags().StringVarP(&logsFormat, "format", "o", logFormatPretty, "Output format: pretty renders JSON lines, raw prints lines as-is, json prints NDJSON")
+	logsCmd.Flags().StringArrayVar(&logsFields, "field", nil, "Only show JSON lines where key equals value (key=value, repeatable)")
+	logsCmd.Flags().StringVar(&logsLevel, "level", "", "Only show JSON lines at or above this level (debug, info, warn, error)")
+	logsCmd.Flags().StringVar(&logsOutputDir, "output-dir", "", "Write each pod/container to its own file in this directory")
+	logsCmd.Flags().Int64Var(&logsMaxSize, "max-size", 100, "Rotate log files at this size in megabytes (0 disables rotation)")
+	logsCmd.Flags().BoolVar(&logsGzip, "gzip", false, "Gzip rotated log files")
 }
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logExporter writes each pod/container to its own file under dir, rotating
+// files once they reach maxSize bytes. Files are flushed every
+// logFlushInterval so a running capture can be tailed. It is safe for
+// concurrent use.
+type logExporter struct {
+	printer  *logPrinter
+	dir      string
+	maxSize  int64
+	compress bool
+
+	mu    sync.Mutex
+	files map[string]*rotatingFile
+	errs  []error
+	// gzips tracks compressions of rotated files, which run without mu held.
+	gzips sync.WaitGroup
+	// stop ends the flush loop, which closes flushed when it returns.
+	stop    chan struct{}
+	flushed chan struct{}
+}
+
+const logFlushInterval = time.Second
+
+func newLogExporter(printer *logPrinter, dir string, maxSize int64, compress bool) (*logExporter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	printer.color = false
+	e := &logExporter{
+		printer:  printer,
+		dir:      dir,
+		maxSize:  maxSize,
+		compress: compress,
+		files:    map[string]*rotatingFile{},
+		stop:     make(chan struct{}),
+		flushed:  make(chan struct{}),
+	}
+	go e.flushLoop()
+	return e, nil
+}
+
+// flushLoop flushes buffered lines until close, including those of
+// containers that have gone quiet.
+func (e *logExporter) flushLoop() {
+	defer close(e.flushed)
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+		e.mu.Lock()
+		for _, file := range e.files {
+			if err := file.flush(); err != nil {
+				e.addError(fmt.Errorf("%s: %w", file.path, err))
+			}
+		}
+		e.mu.Unlock()
+	}
+}
+
+func (e *logExporter) write(line logLine) {
+	out, ok := e.printer.render(line, false)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := line.Pod + "_" + line.Container
+	file, ok := e.files[key]
+	if !ok {
+		file = &rotatingFile{path: filepath.Join(e.dir, key+".log"), maxSize: e.maxSize}
+		if e.compress {
+			file.onRotate = e.gzipRotated
+		}
+		e.files[key] = file
+		fmt.Fprintf(os.Stderr, "Writing %s/%s to %s\n", line.Pod, line.Container, file.path)
+	}
+	if err := file.writeLine(out); err != nil {
+		e.addError(fmt.Errorf("%s: %w", file.path, err))
+	}
+}
+
+// addError records err; e.mu must be held.
+func (e *logExporter) addError(err error) {
+	if len(e.errs) < 10 {
+		e.errs = append(e.errs, err)
+	}
+}
+
+// gzipRotated compresses a rotated file in the background so writers of
+// other containers are not blocked meanwhile. It is called with e.mu held.
+func (e *logExporter) gzipRotated(path string) {
+	e.gzips.Add(1)
+	go func() {
+		defer e.gzips.Done()
+		if err := gzipFile(path); err != nil {
+			e.mu.Lock()
+			e.addError(fmt.Errorf("%s: %w", path, err))
+			e.mu.Unlock()
+		}
+	}()
+}
+
+// close flushes and closes every file, waits for pending compressions and
+// returns the first error.
+func (e *logExporter) close() error {
+	close(e.stop)
+	<-e.flushed
+
+	e.mu.Lock()
+	for _, file := range e.files {
+		if err := file.close(); err != nil {
+			e.addError(err)
+		}
+	}
+	e.mu.Unlock()
+	e.gzips.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "Wrote logs of %d containers to %s\n", len(e.files), e.dir)
+	if len(e.errs) > 0 {
+		return e.errs[0]
+	}
+	return nil
+}
+
+// rotatingFile is a buffered log file that is moved aside once it grows past
+// maxSize. onRotate, if set, is called with the path it was moved to.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	onRotate func(rotated string)
+
+	file *os.File
+	buf  *bufio.Writer
+	size int64
+}
+
+func (f *rotatingFile) writeLine(line string) error {
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line))+1 > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.buf.WriteString(line + "\n")
+	f.size += int64(n)
+	return err
+}
+
+// flush writes buffered lines to the file, if it is open.
+func (f *rotatingFile) flush() error {
+	if f.file == nil || f.buf.Buffered() == 0 {
+		return nil
+	}
+	return f.buf.Flush()
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.buf = bufio.NewWriterSize(file, 64*1024)
+	f.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file with a timestamp suffix and starts a new
+// one.
+func (f *rotatingFile) rotate() error {
+	if err := f.close(); err != nil {
+		return err
+	}
+	rotated := rotatedName(f.path, time.Now())
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	if f.onRotate != nil {
+		f.onRotate(rotated)
+	}
+	return f.open()
+}
+
+// rotatedName returns a timestamped name for path that is not taken yet,
+// neither plain nor gzipped.
+func rotatedName(path string, now time.Time) string {
+	base := fmt.Sprintf("%s-%s", strings.TrimSuffix(path, ".log"), now.Format("20060102T150405.000"))
+	name := base + ".log"
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%d.log", base, i)
+	}
+	return name
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (f *rotatingFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.buf.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file = nil
+	return err
+}
+
+// gzipFile replaces path with path.gz.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"golang.org/x/term"
+)
+
+const (
+	logFormatRaw    = "raw"
+	logFormatPretty = "pretty"
+	logFormatJSON   = "json"
+)
+
+// Field names commonly used by structured loggers, in order of preference.
+var (
+	logLevelKeys   = []string{"level", "lvl", "severity", "log.level"}
+	logMessageKeys = []string{"msg", "message", "event"}
+	logTimeKeys    = []string{"time", "timestamp", "ts", "@timestamp"}
+)
+
+// Log levels by rank. Numeric levels are the ones used by pino and bunyan.
+var logLevels = map[string]int{
+	"trace": 10, "debug": 20, "info": 30, "notice": 30,
+	"warn": 40, "warning": 40, "error": 50, "err": 50,
+	"fatal": 60, "critical": 60, "panic": 60,
+}
+
+// logPrinter filters and renders log lines. It is safe for concurrent use.
+type logPrinter struct {
+	mu    sync.Mutex
+	out   io.Writer
+	color bool
+
+	format   string
+	fields   map[string]string
+	minLevel int
+}
+
+func newLogPrinter(out *os.File) *logPrinter {
+	return &logPrinter{out: out, color: term.IsTerminal(int(out.Fd())), format: logFormatRaw}
+}
+
+// configure applies --format, --field and --level.
+func (p *logPrinter) configure(format string, fields []string, level string) error {
+	switch format {
+	case logFormatRaw, logFormatPretty, logFormatJSON:
+		p.format = format
+	default:
+		return fmt.Errorf("unknown format %q, use pretty, raw or json", format)
+	}
+
+	p.fields = map[string]string{}
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("--field must look like key=value, got %q", field)
+		}
+		p.fields[key] = value
+	}
+
+	if level != "" {
+		rank, ok := logLevels[strings.ToLower(level)]
+		if !ok {
+			return fmt.Errorf("unknown level %q", level)
+		}
+		p.minLevel = rank
+	}
+	return nil
+}
+
+// Colors used for pod/container tags, picked by hashing the tag.
+var logTagColors = []text.Color{
+	text.FgCyan, text.FgGreen, text.FgYellow, text.FgMagenta, text.FgBlue,
+	text.FgHiCyan, text.FgHiGreen, text.FgHiYellow, text.FgHiMagenta, text.FgHiBlue,
+}
+
+func (p *logPrinter) print(line logLine) {
+	out, ok := p.render(line, true)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.out, out)
+}
+
+// render filters and formats a line. withTag prefixes it with the pod and
+// container, which files written per container do not need.
+func (p *logPrinter) render(line logLine, withTag bool) (string, bool) {
+	prefix, fields := parseLogLine(line.Text)
+	if !p.keep(fields) {
+		return "", false
+	}
+
+	var out string
+	switch {
+	case p.format == logFormatJSON:
+		return p.renderJSON(line, prefix, fields), true
+	case p.format == logFormatPretty && fields != nil:
+		out = p.renderPretty(prefix, fields)
+	default:
+		out = line.Text
+	}
+	if withTag {
+		out = p.tag(line) + " " + out
+	}
+	return out, true
+}
+
+func (p *logPrinter) tag(line logLine) string {
+	tag := fmt.Sprintf("[%s/%s]", line.Pod, line.Container)
+	if !p.color {
+		return tag
+	}
+	h := fnv.New32a()
+	h.Write([]byte(tag))
+	return logTagColors[h.Sum32()%uint32(len(logTagColors))].Sprint(tag)
+}
+
+// keep applies the --field and --level filters. Lines that are not JSON
+// only pass when neither filter is set.
+func (p *logPrinter) keep(fields map[string]interface{}) bool {
+	if len(p.fields) == 0 && p.minLevel == 0 {
+		return true
+	}
+	if fields == nil {
+		return false
+	}
+	for key, want := range p.fields {
+		value, ok := fields[key]
+		if !ok || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	if p.minLevel > 0 {
+		rank, ok := levelRank(fields)
+		if !ok || rank < p.minLevel {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *logPrinter) renderPretty(prefix string, fields map[string]interface{}) string {
+	var parts []string
+	if prefix != "" {
+		parts = append(parts, prefix)
+	}
+
+	used := map[string]bool{}
+	if key, value, ok := firstField(fields, logTimeKeys); ok {
+		used[key] = true
+		parts = append(parts, p.colorize(text.Faint, formatLogTime(value)))
+	}
+	if key, value, ok := firstField(fields, logLevelKeys); ok {
+		used[key] = true
+		level := strings.ToUpper(levelName(value))
+		parts = append(parts, p.colorize(levelColor(value), fmt.Sprintf("%-5s", level)))
+	}
+	if key, value, ok := firstField(fields, logMessageKeys); ok {
+		used[key] = true
+		parts = append(parts, fmt.Sprint(value))
+	}
+
+	var keys []string
+	for key := range fields {
+		if !used[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := fields[key]
+		if _, isString := value.(string); !isString {
+			if encoded, err := json.Marshal(value); err == nil {
+				value = string(encoded)
+			}
+		}
+		parts = append(parts, p.colorize(text.Faint, fmt.Sprintf("%s=%v", key, value)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// renderJSON emits one JSON object per line with the pod and container the
+// line came from. Lines that are not JSON are kept as strings.
+func (p *logPrinter) renderJSON(line logLine, prefix string, fields map[string]interface{}) string {
+	record := map[string]interface{}{
+		"pod":       line.Pod,
+		"container": line.Container,
+	}
+	if prefix != "" {
+		record["timestamp"] = prefix
+	}
+	if fields != nil {
+		record["log"] = fields
+	} else {
+		record["log"] = line.Text
+	}
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return line.Text
+	}
+	return string(encoded)
+}
+
+func (p *logPrinter) colorize(color text.Color, s string) string {
+	if !p.color {
+		return s
+	}
+	return color.Sprint(s)
+}
+
+// parseLogLine splits an optional Kubernetes timestamp (from --timestamps)
+// off a line and decodes the rest if it is a JSON object.
+func parseLogLine(line string) (string, map[string]interface{}) {
+	prefix, rest := "", line
+	if !strings.HasPrefix(rest, "{") {
+		if i := strings.IndexByte(rest, ' '); i > 0 && strings.HasPrefix(rest[i+1:], "{") {
+			prefix, rest = rest[:i], rest[i+1:]
+		}
+	}
+	if !strings.HasPrefix(rest, "{") {
+		return "", nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(rest), &fields); err != nil {
+		return "", nil
+	}
+	return prefix, fields
+}
+
+func firstField(fields map[string]interface{}, keys []string) (string, interface{}, bool) {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok {
+			return key, value, true
+		}
+	}
+	return "", nil, false
+}
+
+// levelName turns numeric levels into names and leaves others untouched.
+func levelName(value interface{}) string {
+	if n, ok := value.(float64); ok {
+		switch {
+		case n >= 60:
+			return "fatal"
+		case n >= 50:
+			return "error"
+		case n >= 40:
+			return "warn"
+		case n >= 30:
+			return "info"
+		case n >= 20:
+			return "debug"
+		default:
+			return "trace"
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+func levelRank(fields map[string]interface{}) (int, bool) {
+	_, value, ok := firstField(fields, logLevelKeys)
+	if !ok {
+		return 0, false
+	}
+	rank, ok := logLevels[strings.ToLower(levelName(value))]
+	return rank, ok
+}
+
+func levelColor(value interface{}) text.Color {
+	switch rank := logLevels[strings.ToLower(levelName(value))]; {
+	case rank >= 50:
+		return text.FgRed
+	case rank >= 40:
+		return text.FgYellow
+	case rank >= 30:
+		return text.FgGreen
+	default:
+		return text.FgBlue
+	}
+}
+
+// formatLogTime shortens RFC3339 and epoch timestamps to local time of day.
+func formatLogTime(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t.Local().Format("15:04:05.000")
+		}
+		return v
+	case float64:
+		// Epoch seconds or milliseconds.
+		if v > 1e12 {
+			v /= 1000
+		}
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9)).Local().Format("15:04:05.000")
+	default:
+		return fmt.Sprint(v)
+	}
+}
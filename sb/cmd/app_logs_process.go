@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// processLabel is the pod label that tells web pods ("web") from the pods of
+// each worker process (the worker's key). Init processes run as init
+// containers of the web pods, named after their key.
+const processLabel = "process"
+
+const (
+	processAll    = "all"
+	processWeb    = "web"
+	processWorker = "worker"
+	processInit   = "init"
+)
+
+// logProcess selects the pods and containers of one app process.
+type logProcess struct {
+	Kind string
+	Name string
+}
+
+// parseLogProcess parses web, worker:NAME, init:NAME or all.
+func parseLogProcess(value string) (logProcess, error) {
+	kind, name, _ := strings.Cut(value, ":")
+	switch kind {
+	case processAll, processWeb:
+		if name != "" {
+			return logProcess{}, fmt.Errorf("--process %s does not take a name", kind)
+		}
+	case processWorker, processInit:
+		if name == "" {
+			return logProcess{}, fmt.Errorf("--process %s needs a name, e.g. %s:NAME", kind, kind)
+		}
+	default:
+		return logProcess{}, fmt.Errorf("unknown process %q, use web, worker:NAME, init:NAME or all", value)
+	}
+	return logProcess{Kind: kind, Name: name}, nil
+}
+
+// validate checks that the named worker or init process exists on the app.
+func (p logProcess) validate(appUUID string) error {
+	var keys []string
+	switch p.Kind {
+	case processWorker:
+		workers, err := fetchWorkerProcesses(appUUID)
+		if err != nil {
+			return err
+		}
+		for _, worker := range workers {
+			keys = append(keys, worker.Key)
+		}
+	case processInit:
+		initProcesses, err := fetchInitProcesses(appUUID)
+		if err != nil {
+			return err
+		}
+		for _, initProcess := range initProcesses {
+			keys = append(keys, initProcess.Key)
+		}
+	default:
+		return nil
+	}
+
+	for _, key := range keys {
+		if key == p.Name {
+			return nil
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("the app has no %s processes", p.Kind)
+	}
+	return fmt.Errorf("%s process %q not found, the app has: %s", p.Kind, p.Name, strings.Join(keys, ", "))
+}
+
+// matches reports whether a container of pod belongs to the process.
+func (p logProcess) matches(pod *v1.Pod, container string, init bool) bool {
+	process := pod.Labels[processLabel]
+	isWeb := process == "" || process == processWeb
+
+	switch p.Kind {
+	case processWeb:
+		return isWeb && !init
+	case processWorker:
+		return (process == p.Name || process == "worker-"+p.Name) && !init
+	case processInit:
+		return isWeb && init && container == p.Name
+	default:
+		return true
+	}
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+var appPortForwardCmd = &cobra.Command{
+	Use:   "port-forward [LOCAL:]REMOTE...",
+	Short: "Forward local ports to an app pod",
+	Long: `Forwards one or more local ports to a running web pod of an app:
+
+  sb-cli apps port-forward --app myapp 8080:3000 9229
+
+A single port forwards to the same port remotely, and :REMOTE picks a free
+local port. When the pod goes away, the forward reconnects to another one.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  appPortForward,
+}
+
+var (
+	portForwardApp          string
+	portForwardAppAddresses []string
+)
+
+func appPortForward(cmd *cobra.Command, args []string) {
+	if err := validatePortPairs(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	app, err := resolveApp(portForwardApp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cluster, err := connectApp(app.UUID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to the cluster: %v\n", err)
+		os.Exit(1)
+	}
+
+	web := logProcess{Kind: processWeb}
+	forwarder := &portForwarder{
+		cluster:   cluster,
+		selector:  fmt.Sprintf("appUuid=%s", app.UUID),
+		accept:    func(pod *corev1.Pod) bool { return web.matches(pod, "", false) },
+		addresses: portForwardAppAddresses,
+		ports:     args,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := forwarder.run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// validatePortPairs checks LOCAL:REMOTE, PORT and :REMOTE arguments.
+func validatePortPairs(pairs []string) error {
+	for _, pair := range pairs {
+		local, remote, found := strings.Cut(pair, ":")
+		if !found {
+			remote = local
+		}
+		if local != "" {
+			if _, err := strconv.ParseUint(local, 10, 16); err != nil {
+				return fmt.Errorf("invalid local port in %q", pair)
+			}
+		}
+		if port, err := strconv.ParseUint(remote, 10, 16); err != nil || port == 0 {
+			return fmt.Errorf("invalid remote port in %q", pair)
+		}
+	}
+	return nil
+}
+
+// portForwarder forwards local ports to a running pod matching selector and
+// accept, moving on to another pod whenever the connection is lost.
+type portForwarder struct {
+	cluster   *appCluster
+	selector  string
+	accept    func(*corev1.Pod) bool
+	addresses []string
+	ports     []string
+	// Where to report forwarded ports and connections, stdout if nil.
+	out io.Writer
+	// Called once the ports are first being forwarded.
+	onReady func([]portforward.ForwardedPort)
+}
+
+// run forwards until ctx is done.
+func (f *portForwarder) run(ctx context.Context) error {
+	connected := false
+	for {
+		pod, err := f.waitForPod(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		ready, err := f.forward(ctx, pod)
+		if ctx.Err() != nil {
+			return nil
+		}
+		// Failing to forward before ever connecting usually means a bad
+		// port or address, so give up rather than retrying forever.
+		if !connected && !ready && err != nil {
+			return err
+		}
+		connected = connected || ready
+		fmt.Fprintf(os.Stderr, "Lost connection to pod %s, reconnecting...\n", pod.Name)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// waitForPod returns a running, accepted pod, waiting for one to come up if
+// there is none.
+func (f *portForwarder) waitForPod(ctx context.Context) (*corev1.Pod, error) {
+	for {
+		list, err := f.cluster.clientset.CoreV1().Pods(f.cluster.namespace).List(ctx, metav1.ListOptions{LabelSelector: f.selector})
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+		for i := range list.Items {
+			pod := &list.Items[i]
+			if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil && f.accept(pod) {
+				return pod, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// forward forwards the ports to pod until the connection is lost, the pod
+// stops running or ctx is done. It reports whether the ports were ready.
+func (f *portForwarder) forward(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(f.cluster.config)
+	if err != nil {
+		return false, err
+	}
+	req := f.cluster.clientset.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(f.cluster.namespace).
+		SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", req.URL())
+
+	stopChan := make(chan struct{})
+	readyChan := make(chan struct{})
+	out := f.out
+	if out == nil {
+		out = os.Stdout
+	}
+	forwarder, err := portforward.NewOnAddresses(dialer, f.addresses, f.ports, stopChan, readyChan, out, os.Stderr)
+	if err != nil {
+		return false, err
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	ready := false
+	go func() {
+		defer close(done)
+		select {
+		case <-readyChan:
+		case <-watchCtx.Done():
+			close(stopChan)
+			return
+		}
+		// Keep the local ports picked for :REMOTE across reconnects.
+		if ports, err := forwarder.GetPorts(); err == nil {
+			f.ports = f.ports[:0]
+			for _, port := range ports {
+				f.ports = append(f.ports, fmt.Sprintf("%d:%d", port.Local, port.Remote))
+			}
+			if f.onReady != nil {
+				f.onReady(ports)
+				f.onReady = nil
+			}
+		}
+		ready = true
+		f.watchPod(watchCtx, pod.Name)
+		close(stopChan)
+	}()
+
+	err = forwarder.ForwardPorts()
+	cancel()
+	<-done
+	return ready, err
+}
+
+// watchPod returns once the pod is gone or no longer running, or ctx is done.
+func (f *portForwarder) watchPod(ctx context.Context, name string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+		pod, err := f.cluster.clientset.CoreV1().Pods(f.cluster.namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			return
+		}
+	}
+}
+
+func init() {
+	appPortForwardCmd.Flags().StringVar(&portForwardApp, "app", "", "App name or UUID")
+	appPortForwardCmd.Flags().StringSliceVar(&portForwardAppAddresses, "address", []string{"localhost"}, "Addresses to listen on, comma separated")
+	appsCmd.AddCommand(appPortForwardCmd)
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run -- COMMAND [ARGS...]",
+	Short: "Run a one-off command in an app pod",
+	Long: `Runs a command in an app pod through the buildpack launcher, so the app's
+environment is set up as it is for the running app:
+
+  sb-cli apps run --app myapp -- rake db:migrate
+
+stdout and stderr are kept separate and the command's exit code is returned.
+A TTY is allocated when stdin and stdout are terminals; use --tty=false in
+scripts. With --ephemeral, the command runs in a new pod that is removed
+afterwards instead of in a serving replica.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  appRun,
+}
+
+var (
+	runApp       string
+	runTTY       bool
+	runEphemeral bool
+	runTimeout   time.Duration
+)
+
+func appRun(cmd *cobra.Command, args []string) {
+	tty := runTTY
+	if !cmd.Flags().Changed("tty") {
+		tty = term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+	}
+
+	app, err := resolveApp(runApp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cluster, err := connectApp(app.UUID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to the cluster: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Interrupting stops starting the pod or the command, so an ephemeral pod
+	// still gets cleaned up.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pod, err := cluster.clientset.CoreV1().Pods(cluster.namespace).Get(ctx, cluster.pod, metav1.GetOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching pod %s: %v\n", cluster.pod, err)
+		os.Exit(1)
+	}
+
+	if runEphemeral {
+		ephemeral, err := cluster.startEphemeralPod(ctx, pod, app.UUID, runTimeout)
+		if err != nil {
+			code := 130
+			if ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "Error starting pod: %v\n", err)
+				code = 1
+			}
+			if ephemeral != nil {
+				cluster.deletePod(ephemeral.Name)
+			}
+			os.Exit(code)
+		}
+		pod = ephemeral
+	}
+
+	opts := execOptions{
+		Pod:       pod.Name,
+		Container: appContainer(pod),
+		Command:   append([]string{"launcher"}, args...),
+		TTY:       tty,
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+	}
+	// Without a TTY, stdin is only attached when something is piped in, so
+	// commands do not wait on an interactive terminal.
+	if tty || !term.IsTerminal(int(os.Stdin.Fd())) {
+		opts.Stdin = os.Stdin
+	}
+
+	err = cluster.exec(ctx, opts)
+	code := 130
+	if ctx.Err() == nil {
+		code = exitCode(err)
+	}
+	if runEphemeral {
+		cluster.deletePod(pod.Name)
+	}
+	os.Exit(code)
+}
+
+// exitCode maps the result of a remote command to a local exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		return exitErr.ExitStatus()
+	}
+	fmt.Fprintf(os.Stderr, "Error running command: %v\n", err)
+	return 1
+}
+
+// startEphemeralPod creates a copy of the app container of template that
+// idles until commands are exec'd into it, and waits for it to run. It is not
+// labelled as an app pod, so it receives no traffic and is not counted in
+// rollouts. Once created, the pod is returned even on error, including when
+// ctx is cancelled, so the caller can delete it.
+func (c *appCluster) startEphemeralPod(ctx context.Context, template *corev1.Pod, appUUID string, timeout time.Duration) (*corev1.Pod, error) {
+	container := *template.Spec.Containers[0].DeepCopy()
+	container.Command = []string{"sleep"}
+	container.Args = []string{fmt.Sprint(int(timeout.Seconds()))}
+	container.LivenessProbe = nil
+	container.ReadinessProbe = nil
+	container.StartupProbe = nil
+	container.Lifecycle = nil
+	container.Ports = nil
+
+	spec := *template.Spec.DeepCopy()
+	spec.InitContainers = nil
+	spec.Containers = []corev1.Container{container}
+	spec.RestartPolicy = corev1.RestartPolicyNever
+	spec.NodeName = ""
+	activeDeadline := int64(timeout.Seconds())
+	spec.ActiveDeadlineSeconds = &activeDeadline
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: strings.TrimSuffix(template.GenerateName, "-") + "-run-",
+			Labels:       map[string]string{"sbRun": appUUID},
+		},
+		Spec: spec,
+	}
+	if template.GenerateName == "" {
+		pod.GenerateName = template.Name + "-run-"
+	}
+
+	// Creating is not cancelled by ctx, otherwise a pod could be created
+	// without its name ever reaching the caller.
+	createCtx, cancelCreate := context.WithTimeout(context.Background(), 30*time.Second)
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Create(createCtx, pod, metav1.CreateOptions{})
+	cancelCreate()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Starting pod %s...\n", pod.Name)
+
+	startCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	for {
+		current, err := c.clientset.CoreV1().Pods(c.namespace).Get(startCtx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return pod, err
+		}
+		if current.Status.Phase == corev1.PodRunning {
+			return current, nil
+		}
+		if err := podFailure(current); err != nil {
+			return pod, err
+		}
+		if current.Status.Phase == corev1.PodSucceeded {
+			return pod, fmt.Errorf("pod %s exited before the command could run", pod.Name)
+		}
+
+		select {
+		case <-startCtx.Done():
+			if ctx.Err() != nil {
+				return pod, ctx.Err()
+			}
+			return pod, fmt.Errorf("timed out waiting for pod %s to start", pod.Name)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (c *appCluster) deletePod(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.clientset.CoreV1().Pods(c.namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to delete pod %s: %v\n", name, err)
+	}
+}
+
+func init() {
+	runCmd.Flags().SetInterspersed(false)
+	runCmd.Flags().StringVar(&runApp, "app", "", "App name or UUID")
+	runCmd.Flags().BoolVarP(&runTTY, "tty", "t", false, "Allocate a TTY (default when stdin and stdout are terminals)")
+	runCmd.Flags().BoolVar(&runEphemeral, "ephemeral", false, "Run in a new pod instead of a serving replica")
+	runCmd.Flags().DurationVar(&runTimeout, "timeout", 12*time.Hour, "Maximum lifetime of the pod created by --ephemeral")
+	appsCmd.AddCommand(runCmd)
+}
@@ -5,12 +5,18 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
 )
@@ -22,115 +28,336 @@ type ShellInfo struct {
 }
 
 var shellCmd = &cobra.Command{
-	Use:     "shell",
-	Short:   "App shell",
+	Use:   "shell [-- COMMAND [ARGS...]]",
+	Short: "App shell",
+	Long: `Opens an interactive shell in an app pod. By default this is the pod picked
+by the server; use --pod, or --process worker:NAME to pick a worker pod, and
+--container to pick a container other than the app container.
+
+bash is started unless a command is given, either with --command, which is
+run by bash -c, or after --, which is passed on as is:
+
+  sb-cli apps shell --app myapp --command "rails console"
+  sb-cli apps shell --app myapp --command "echo 'hello world' > /tmp/greeting"
+  sb-cli apps shell --app myapp -- bash -c 'echo hi'
+
+The terminal is put into raw mode and window size changes are passed on.
+Exit the shell or press Ctrl-D to close it.`,
 	Aliases: []string{"sh"},
 	Run:     appShell,
 }
 
+var (
+	shellApp       string
+	shellPod       string
+	shellContainer string
+	shellProcess   string
+	shellCommand   string
+)
+
 func appShell(cmd *cobra.Command, args []string) {
-	apps, err := fetchApps()
+	var process logProcess
+	if shellProcess != "" {
+		var err error
+		process, err = parseLogProcess(shellProcess)
+		if err == nil && process.Kind == processInit {
+			err = fmt.Errorf("init processes have exited by the time the app runs, use web or worker:NAME")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	command := args
+	if cmd.Flags().Changed("command") {
+		if len(args) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: use either --command or a command after --, not both")
+			os.Exit(1)
+		}
+		if strings.TrimSpace(shellCommand) == "" {
+			fmt.Fprintln(os.Stderr, "Error: --command must not be empty")
+			os.Exit(1)
+		}
+		command = []string{"bash", "-c", shellCommand}
+	}
+	if len(command) == 0 {
+		command = []string{"bash"}
+	}
+
+	app, err := resolveApp(shellApp)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error fetching apps: %v\n", err)
-		return
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := process.validate(app.UUID); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	app := selectApp(apps)
+	cluster, err := connectApp(app.UUID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching shell info: %v\n", err)
+		os.Exit(1)
+	}
 
-	// API call
-	sbUrl, token, _, err := getContext()
+	pod, err := cluster.selectPod(context.Background(), app.UUID, shellPod, process)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
-		return
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	container, err := podContainer(pod, shellContainer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-	fullUrl := fmt.Sprintf("%s/apps/%s/shell-info/", sbUrl, app.UUID)
 
-	req, err := http.NewRequest("GET", fullUrl, nil)
+	tty := term.IsTerminal(int(os.Stdin.Fd()))
+	if tty {
+		fmt.Fprintf(os.Stderr, "Connected to %s/%s. Press Ctrl-D or type exit to leave.\n", pod.Name, container)
+	}
+	err = cluster.exec(context.Background(), execOptions{
+		Pod:       pod.Name,
+		Container: container,
+		Command:   append([]string{"launcher"}, command...),
+		TTY:       tty,
+		Stdin:     os.Stdin,
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+	})
+	os.Exit(exitCode(err))
+}
+
+// fetchShellInfo returns the pod, namespace and base64 encoded kubeconfig the
+// server hands out for running commands against an app.
+func fetchShellInfo(appUUID string) (ShellInfo, error) {
+	sbUrl, token, _, err := getContext()
 	if err != nil {
-		fmt.Println(err)
+		return ShellInfo{}, err
 	}
 
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/apps/%s/shell-info/", sbUrl, appUUID), nil)
+	if err != nil {
+		return ShellInfo{}, err
+	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return ShellInfo{}, err
 	}
-
 	defer resp.Body.Close()
-	var shellInfo ShellInfo
-	if err := json.NewDecoder(resp.Body).Decode(&shellInfo); err != nil {
-		fmt.Printf("Unable to decode podinfo from response: %v\n", err)
-		os.Exit(1)
-	}
-	fmt.Printf("Received pod name: %s\n", shellInfo.Name)
-	fmt.Printf("Namespace: %s\n", shellInfo.Namespace)
-	fmt.Printf("KubeConfig: %s\n", shellInfo.KubeConfig)
 
-	decodedKubeConfig, err := base64.StdEncoding.DecodeString(shellInfo.KubeConfig)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to decode kubeconfig: %v\n", err)
-		os.Exit(1)
+	if resp.StatusCode != http.StatusOK {
+		return ShellInfo{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	if err = execIntoPod(shellInfo.Name, shellInfo.Namespace, string(decodedKubeConfig)); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to exec into pod: %v\n", err)
-		os.Exit(1)
+	var shellInfo ShellInfo
+	if err := json.NewDecoder(resp.Body).Decode(&shellInfo); err != nil {
+		return ShellInfo{}, fmt.Errorf("unable to decode shell info: %w", err)
 	}
+	return shellInfo, nil
+}
 
+// appCluster is a connection to the cluster an app runs in, built from the
+// kubeconfig returned by shell-info.
+type appCluster struct {
+	config    *rest.Config
+	clientset *kubernetes.Clientset
+	namespace string
+	// The serving pod shell-info picked for the app.
+	pod string
 }
 
-func execIntoPod(podName, namespace, kubeConfig string) error {
-	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeConfig))
+func connectApp(appUUID string) (*appCluster, error) {
+	shellInfo, err := fetchShellInfo(appUUID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	clientset, err := kubernetes.NewForConfig(config)
+	kubeConfig, err := base64.StdEncoding.DecodeString(shellInfo.KubeConfig)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to decode kubeconfig: %v", err)
 	}
-
-	pod, err := clientset.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
 	}
+	return &appCluster{config: config, clientset: clientset, namespace: shellInfo.Namespace, pod: shellInfo.Name}, nil
+}
+
+// execOptions describes a command to run in a pod container.
+type execOptions struct {
+	Pod       string
+	Container string
+	Command   []string
+	TTY       bool
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+}
 
-	// TODO: send EOF signal correctly
-	req := clientset.CoreV1().RESTClient().
+// exec runs a command in a pod. A non-zero exit status of the command is
+// returned as a utilexec.ExitError. With a TTY and a terminal on stdin, the
+// terminal is put into raw mode and resized along with the remote one.
+func (c *appCluster) exec(ctx context.Context, opts execOptions) error {
+	req := c.clientset.CoreV1().RESTClient().
 		Post().
 		Resource("pods").
-		Name(podName).
-		Namespace(namespace).
+		Name(opts.Pod).
+		Namespace(c.namespace).
 		SubResource("exec").
-		Param("container", pod.Spec.Containers[0].Name).
-		Param("stdin", "true").
-		Param("stdout", "true").
-		Param("stderr", "true").
-		Param("tty", "true").
-		Param("command", "launcher").
-		Param("command", "bash")
-
-	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+		Param("container", opts.Container).
+		Param("stdin", fmt.Sprint(opts.Stdin != nil)).
+		Param("stdout", fmt.Sprint(opts.Stdout != nil)).
+		Param("stderr", fmt.Sprint(opts.Stderr != nil && !opts.TTY)).
+		Param("tty", fmt.Sprint(opts.TTY))
+	for _, arg := range opts.Command {
+		req = req.Param("command", arg)
+	}
+
+	exec, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
 	if err != nil {
 		return err
 	}
+	streamOptions := remotecommand.StreamOptions{
+		Stdin:  opts.Stdin,
+		Stdout: opts.Stdout,
+		Tty:    opts.TTY,
+	}
+	// With a TTY, stderr is merged into stdout by the remote terminal.
+	if !opts.TTY {
+		streamOptions.Stderr = opts.Stderr
+	}
 
-	err = exec.StreamWithContext(context.Background(), remotecommand.StreamOptions{
-		Stdout: os.Stdout,
-		Stdin:  os.Stdin,
-		Stderr: os.Stderr,
-		Tty:    true,
-	})
+	if stdin, ok := opts.Stdin.(*os.File); ok && opts.TTY && term.IsTerminal(int(stdin.Fd())) {
+		// In raw mode Ctrl-C, Ctrl-D and friends are sent to the remote
+		// terminal as bytes instead of being handled locally.
+		state, err := term.MakeRaw(int(stdin.Fd()))
+		if err != nil {
+			return err
+		}
+		defer term.Restore(int(stdin.Fd()), state)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		streamOptions.TerminalSizeQueue = newTerminalSizeQueue(ctx, int(stdin.Fd()))
+	}
+	return exec.StreamWithContext(ctx, streamOptions)
+}
+
+// appContainer returns the name of the app container of pod.
+func appContainer(pod *corev1.Pod) string {
+	return pod.Spec.Containers[0].Name
+}
+
+// podContainer returns name if pod has such a container, or the app
+// container if name is empty.
+func podContainer(pod *corev1.Pod, name string) (string, error) {
+	if name == "" {
+		return appContainer(pod), nil
+	}
+	var names []string
+	for _, container := range pod.Spec.Containers {
+		if container.Name == name {
+			return name, nil
+		}
+		names = append(names, container.Name)
+	}
+	return "", fmt.Errorf("pod %s has no container %q, it has: %s", pod.Name, name, strings.Join(names, ", "))
+}
+
+// selectPod returns the named pod, a running pod of process, or the pod
+// picked by shell-info, in that order of preference.
+func (c *appCluster) selectPod(ctx context.Context, appUUID, name string, process logProcess) (*corev1.Pod, error) {
+	pods := c.clientset.CoreV1().Pods(c.namespace)
+	if name == "" && process.Kind == "" {
+		name = c.pod
+	}
+	if name != "" {
+		pod, err := pods.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if pod.Labels["appUuid"] != appUUID {
+			return nil, fmt.Errorf("pod %s does not belong to the app", name)
+		}
+		return pod, nil
+	}
+
+	list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("appUuid=%s", appUUID)})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	for i := range list.Items {
+		pod := &list.Items[i]
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil && process.matches(pod, "", false) {
+			return pod, nil
+		}
+	}
+	return nil, fmt.Errorf("no running pods found for process %s", shellProcessName(process))
+}
+
+func shellProcessName(process logProcess) string {
+	if process.Name == "" {
+		return process.Kind
+	}
+	return process.Kind + ":" + process.Name
+}
+
+// terminalSizeQueue reports the local terminal size whenever it changes.
+type terminalSizeQueue struct {
+	sizes chan remotecommand.TerminalSize
+}
+
+func newTerminalSizeQueue(ctx context.Context, fd int) *terminalSizeQueue {
+	q := &terminalSizeQueue{sizes: make(chan remotecommand.TerminalSize, 1)}
+	go func() {
+		defer close(q.sizes)
+		var last remotecommand.TerminalSize
+		resized := notifyResize(ctx)
+		for {
+			if width, height, err := term.GetSize(fd); err == nil {
+				size := remotecommand.TerminalSize{Width: uint16(width), Height: uint16(height)}
+				if size != last {
+					last = size
+					select {
+					case q.sizes <- size:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			select {
+			case <-resized:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return q
+}
+
+// Next blocks until the terminal size changes. It returns nil once the
+// session has ended.
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	size, ok := <-q.sizes
+	if !ok {
+		return nil
 	}
-	return nil
+	return &size
 }
 
 func init() {
+	shellCmd.Flags().SetInterspersed(false)
+	shellCmd.Flags().StringVar(&shellApp, "app", "", "App name or UUID")
+	shellCmd.Flags().StringVar(&shellPod, "pod", "", "Pod to open the shell in")
+	shellCmd.Flags().StringVarP(&shellContainer, "container", "c", "", "Container to open the shell in (default the app container)")
+	shellCmd.Flags().StringVar(&shellProcess, "process", "", "Pick a running pod of this process: web or worker:NAME")
+	shellCmd.Flags().StringVar(&shellCommand, "command", "", "Command to run with bash -c instead of an interactive bash, e.g. \"rails console\"")
 	appsCmd.AddCommand(shellCmd)
 }
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"context"
+	"time"
+)
+
+// notifyResize polls for window size changes, as Windows consoles do not
+// send SIGWINCH.
+func notifyResize(ctx context.Context) <-chan struct{} {
+	resized := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case resized <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return resized
+}
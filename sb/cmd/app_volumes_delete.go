@@ -81,8 +81,7 @@ func volumeDelete(cmd *cobra.Command, args []string) {
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -126,8 +126,7 @@ func appWorkerAdd(cmd *cobra.Command, args []string) {
 		req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
 		// Send the request using the default client
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := doRequest(req)
 		if err != nil {
 			fmt.Println(err)
 			return
@@ -57,8 +57,7 @@ var autodeployCmd = &cobra.Command{
 		req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
 		// Send the request
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := doRequest(req)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error performing request: %v\n", err)
 			return
@@ -175,8 +175,7 @@ func fetchAppDetail(appUuid string) (AppDetail, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return AppDetail{}, err
 	}
@@ -202,8 +201,7 @@ func fetchApps() ([]App, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -231,8 +229,7 @@ func fetchVolume(appUuid string) ([]Volume, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -260,10 +257,9 @@ func fetchEnvVar(appUuid string) ([]EnvVar, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %v", err)
+		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -293,10 +289,9 @@ func fetchBuildVars(appUuid string) ([]BuildVar, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %v", err)
+		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -325,8 +320,7 @@ func fetchSecret(appUUID string) ([]SecretVar, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -372,6 +366,28 @@ func selectApp(apps []App) App {
 	return apps[index]
 }
 
+// resolveApp finds an app by name or UUID, prompting for one when name is
+// empty.
+func resolveApp(name string) (App, error) {
+	apps, err := fetchApps()
+	if err != nil {
+		return App{}, err
+	}
+	if name == "" {
+		app := selectApp(apps)
+		if app.UUID == "" {
+			return App{}, fmt.Errorf("no app selected")
+		}
+		return app, nil
+	}
+	for _, app := range apps {
+		if app.Name == name || app.UUID == name {
+			return app, nil
+		}
+	}
+	return App{}, fmt.Errorf("app %q not found", name)
+}
+
 func selectEnvVars(selectedPos int, allVars []*EnvVarSelect) ([]*EnvVarSelect, error) {
 	const doneKey = "Done"
 	if len(allVars) > 0 && allVars[0].Key != doneKey {
@@ -691,8 +707,7 @@ func fetchInitProcesses(appUUID string) ([]InitProcessRead, error) {
 
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -757,8 +772,7 @@ func fetchWorkerProcesses(appUUID string) ([]WorkerProcess, error) {
 
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -828,10 +842,9 @@ func fetchCustomDomains(appUUID string) ([]CustomDomain, error) {
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
 	// Send the request using the default client
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -57,8 +57,7 @@ func appScale(cmd *cobra.Command, args []string) {
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error performing request: %v\n", err)
 		return
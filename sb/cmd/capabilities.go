@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Optional server features. The open source server ships without clusters,
+// providers and registration, and GitHub only works once a GitHub client is
+// configured on the server.
+const (
+	FeatureClusters     = "clusters"
+	FeatureProviders    = "providers"
+	FeatureRegistration = "registration"
+	FeatureGithub       = "github"
+)
+
+// capabilitiesTTL is how long discovered capabilities are trusted before they
+// are fetched again.
+const capabilitiesTTL = 24 * time.Hour
+
+// capabilitiesRefreshTimeout bounds refreshing stale capabilities before
+// commands that do not need them, see warnIfIncompatible.
+const capabilitiesRefreshTimeout = 3 * time.Second
+
+type Capabilities struct {
+	Version       string   `json:"version,omitempty"`
+	MinCLIVersion string   `json:"min_cli_version,omitempty"`
+	Server        string   `json:"server,omitempty"`
+	Features      []string `json:"features"`
+	CheckedAt     string   `json:"checked_at,omitempty"`
+}
+
+func (c Capabilities) Has(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Capabilities) stale() bool {
+	checkedAt, err := time.Parse(time.RFC3339, c.CheckedAt)
+	if err != nil {
+		return true
+	}
+	return time.Since(checkedAt) > capabilitiesTTL
+}
+
+// discoverCapabilities asks the server which features it supports. Servers
+// without the version endpoint are probed feature by feature instead.
+func discoverCapabilities(sbUrl, token string) (Capabilities, error) {
+	return discoverCapabilitiesContext(context.Background(), sbUrl, token)
+}
+
+// discoverCapabilitiesContext is discoverCapabilities bounded by ctx.
+func discoverCapabilitiesContext(ctx context.Context, sbUrl, token string) (Capabilities, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/version/", sbUrl), nil)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+	}
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	defer resp.Body.Close()
+
+	var caps Capabilities
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&caps) != nil || caps.Features == nil {
+		caps, err = probeCapabilities(ctx, sbUrl, token)
+		if err != nil {
+			return Capabilities{}, err
+		}
+	}
+
+	if caps.Server == "" {
+		caps.Server = "oss"
+		if caps.Has(FeatureRegistration) {
+			caps.Server = "saas"
+		}
+	}
+	caps.CheckedAt = time.Now().Format(time.RFC3339)
+	return caps, nil
+}
+
+// probeCapabilities detects features by checking which endpoints the server
+// answers with 404.
+func probeCapabilities(ctx context.Context, sbUrl, token string) (Capabilities, error) {
+	probes := []struct {
+		feature string
+		method  string
+		path    string
+	}{
+		{FeatureRegistration, "POST", "/api/auth/registration/"},
+		{FeatureClusters, "GET", "/api/clusters/"},
+		{FeatureProviders, "GET", "/api/providers/"},
+		{FeatureGithub, "GET", "/api/github-client/"},
+	}
+
+	caps := Capabilities{Features: []string{}}
+	for _, probe := range probes {
+		req, err := http.NewRequestWithContext(ctx, probe.method, sbUrl+probe.path, nil)
+		if err != nil {
+			return Capabilities{}, err
+		}
+		req.Header.Add("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+		}
+
+		resp, err := doRequest(req)
+		if err != nil {
+			return Capabilities{}, err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			caps.Features = append(caps.Features, probe.feature)
+		}
+	}
+	return caps, nil
+}
+
+// serverCapabilities returns the capabilities of the current context,
+// refreshing the cached copy in the config file once it goes stale.
+func serverCapabilities() (Capabilities, error) {
+	sbUrl, token, _, err := getContext()
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	configFile := viper.ConfigFileUsed()
+	cfg, err := readConfig(configFile)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	contextInfo := cfg.Contexts[cfg.CurrentContext]
+	if contextInfo.Capabilities != nil && !contextInfo.Capabilities.stale() {
+		return *contextInfo.Capabilities, nil
+	}
+
+	caps, err := discoverCapabilities(sbUrl, token)
+	if err != nil {
+		// An outdated answer is better than none when the server is unreachable.
+		if contextInfo.Capabilities != nil {
+			return *contextInfo.Capabilities, nil
+		}
+		return Capabilities{}, err
+	}
+
+	contextInfo.Capabilities = &caps
+	cfg.Contexts[cfg.CurrentContext] = contextInfo
+	if err := writeConfig(configFile, cfg); err != nil {
+		return Capabilities{}, err
+	}
+	return caps, nil
+}
+
+// cachedCapabilities returns the capabilities stored for the current context
+// without contacting the server.
+func cachedCapabilities() (Capabilities, bool) {
+	cfg, err := readConfig(viper.ConfigFileUsed())
+	if err != nil {
+		return Capabilities{}, false
+	}
+	contextInfo, ok := cfg.Contexts[cfg.CurrentContext]
+	if !ok || contextInfo.Capabilities == nil {
+		return Capabilities{}, false
+	}
+	return *contextInfo.Capabilities, true
+}
+
+// requireFeature is used as a PersistentPreRun to stop commands the current
+// server cannot handle before they prompt for anything.
+func requireFeature(feature, message string) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		caps, err := serverCapabilities()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking server capabilities: %v\n", err)
+			os.Exit(1)
+		}
+		if !caps.Has(feature) {
+			fmt.Fprintln(os.Stderr, message)
+			os.Exit(1)
+		}
+	}
+}
+
+// hideUnsupportedCommands hides commands from help output when the cached
+// capabilities say the current server does not support them.
+func hideUnsupportedCommands() {
+	caps, ok := cachedCapabilities()
+	if !ok {
+		return
+	}
+	clustersCmd.Hidden = !caps.Has(FeatureClusters)
+	providersCmd.Hidden = !caps.Has(FeatureProviders)
+	githubAuthCmd.Hidden = !caps.Has(FeatureGithub)
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Kinds of transport failures reported by NetworkError.
+const (
+	NetworkErrorDNS        = "dns"
+	NetworkErrorTLS        = "tls"
+	NetworkErrorTimeout    = "timeout"
+	NetworkErrorConnection = "connection"
+)
+
+// NetworkError is returned when a request never got an HTTP response from the
+// ShapeBlock server, e.g. because the host could not be resolved, the TLS
+// handshake failed or the connection was refused.
+type NetworkError struct {
+	Kind     string
+	Endpoint string
+	Context  string
+	Err      error
+}
+
+func (e *NetworkError) Error() string {
+	var msg string
+	switch e.Kind {
+	case NetworkErrorDNS:
+		msg = fmt.Sprintf("unable to resolve %s", e.Endpoint)
+	case NetworkErrorTLS:
+		msg = fmt.Sprintf("TLS handshake with %s failed", e.Endpoint)
+	case NetworkErrorTimeout:
+		msg = fmt.Sprintf("request to %s timed out", e.Endpoint)
+	default:
+		msg = fmt.Sprintf("unable to connect to %s", e.Endpoint)
+	}
+	if e.Context != "" {
+		msg = fmt.Sprintf("%s (context %q)", msg, e.Context)
+	}
+	return fmt.Sprintf("%s: %v\nHint: %s", msg, e.Err, e.Hint())
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// Hint returns a short remediation for the failure.
+func (e *NetworkError) Hint() string {
+	switch e.Kind {
+	case NetworkErrorDNS:
+		return "check the server address, or use 'sb-cli switch' to select another context"
+	case NetworkErrorTLS:
+		return "trust the server CA with 'sb-cli login --ca-file' or set ca_file on the context"
+	case NetworkErrorTimeout:
+		return "the server did not answer in time, check your network and try again"
+	default:
+		return "check that the server is running and reachable from this machine"
+	}
+}
+
+var (
+	clientsMu   sync.Mutex
+	clients     = map[string]*http.Client{}
+	endpointTLS = map[string]ContextInfo{}
+)
+
+// doRequest sends req with the HTTP client configured for its endpoint.
+// Transport level failures are returned as a *NetworkError so callers never
+// see a nil response with a nil error.
+func doRequest(req *http.Request) (*http.Response, error) {
+	client, err := clientFor(baseURL(req.URL))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newNetworkError(req, err)
+	}
+	return resp, nil
+}
+
+// setEndpointTLS overrides the TLS settings used for endpoint, e.g. while
+// logging in to a server that has no context yet.
+func setEndpointTLS(endpoint string, info ContextInfo) {
+	endpoint = normalizeEndpoint(endpoint)
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	endpointTLS[endpoint] = info
+	delete(clients, endpoint)
+}
+
+// clientFor returns the shared client for endpoint, building it from the TLS
+// settings of the matching context on first use.
+func clientFor(endpoint string) (*http.Client, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if client, ok := clients[endpoint]; ok {
+		return client, nil
+	}
+
+	info, ok := endpointTLS[endpoint]
+	name := endpoint
+	if !ok {
+		name, info, _ = contextForEndpoint(endpoint)
+	}
+
+	client, err := newHTTPClient(info)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings for context %q: %w", name, err)
+	}
+	clients[endpoint] = client
+	return client, nil
+}
+
+// newHTTPClient builds a client that honours HTTPS_PROXY/NO_PROXY and the
+// TLS settings of info.
+func newHTTPClient(info ContextInfo) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if info.hasTLSSettings() {
+		tlsConfig, err := tlsConfigFor(info)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+func tlsConfigFor(info ContextInfo) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: info.InsecureSkipVerify}
+
+	if info.CAFile != "" {
+		caData, err := os.ReadFile(info.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", info.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if info.ClientCert != "" || info.ClientKey != "" {
+		if info.ClientCert == "" || info.ClientKey == "" {
+			return nil, fmt.Errorf("client_cert and client_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(info.ClientCert, info.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// contextForEndpoint finds the configured context serving endpoint,
+// preferring the current context.
+func contextForEndpoint(endpoint string) (string, ContextInfo, bool) {
+	cfg, err := readConfig(viper.ConfigFileUsed())
+	if err != nil {
+		return "", ContextInfo{}, false
+	}
+
+	if info, ok := cfg.Contexts[cfg.CurrentContext]; ok && normalizeEndpoint(info.Endpoint) == endpoint {
+		return cfg.CurrentContext, info, true
+	}
+	for name, info := range cfg.Contexts {
+		if normalizeEndpoint(info.Endpoint) == endpoint {
+			return name, info, true
+		}
+	}
+	return "", ContextInfo{}, false
+}
+
+// normalizeEndpoint reduces a server URL to its scheme and host.
+func normalizeEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	return baseURL(u)
+}
+
+func baseURL(u *url.URL) string {
+	return fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+}
+
+func newNetworkError(req *http.Request, err error) *NetworkError {
+	endpoint := baseURL(req.URL)
+	// Login and register talk to servers that have no context yet, in which
+	// case the context is left empty.
+	name, _, _ := contextForEndpoint(endpoint)
+	return &NetworkError{
+		Kind:     networkErrorKind(err),
+		Endpoint: endpoint,
+		Context:  name,
+		Err:      err,
+	}
+}
+
+func networkErrorKind(err error) string {
+	var dnsErr *net.DNSError
+	var verifyErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return NetworkErrorDNS
+	case errors.As(err, &verifyErr), errors.As(err, &recordErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr):
+		return NetworkErrorTLS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return NetworkErrorTimeout
+	default:
+		return NetworkErrorConnection
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// unreachableEndpoint returns the URL of a server that has already been shut
+// down, so connecting to it is refused.
+func unreachableEndpoint(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.NotFoundHandler())
+	endpoint := server.URL
+	server.Close()
+	return endpoint
+}
+
+// useContext points the config at a single context for endpoint.
+func useContext(t *testing.T, endpoint string) {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "sb.json")
+	config := fmt.Sprintf(`{"current-context": "test", "contexts": {"test": {"endpoint": %q, "server": "oss", "token": "token"}}}`, endpoint)
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(viper.Reset)
+}
+
+func TestUnreachableEndpoint(t *testing.T) {
+	endpoint := unreachableEndpoint(t)
+	useContext(t, endpoint)
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"fetchClusters", func() error {
+			_, err := fetchClusters()
+			return err
+		}},
+		{"fetchProviders", func() error {
+			_, err := fetchProviders()
+			return err
+		}},
+		{"fetchApps", func() error {
+			_, err := fetchApps()
+			return err
+		}},
+		{"login", func() error {
+			_, err := SbLogin("user", "password", endpoint, "oss")
+			return err
+		}},
+		{"register", func() error {
+			_, err := SbRegister(endpoint, "user@example.com", "password", "password")
+			return err
+		}},
+		{"cluster add", func() error {
+			return postCluster(endpoint, "token", Cluster{Name: "test"})
+		}},
+		{"provider add", func() error {
+			return postProvider(endpoint, "token", CloudProvider{Name: "test", Cloud: "aws"})
+		}},
+		{"createDeployment", func() error {
+			_, err := postDeployment(endpoint, "token", "app-uuid", "main")
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("panicked: %v", r)
+				}
+			}()
+
+			err := tt.call()
+			var netErr *NetworkError
+			if !errors.As(err, &netErr) {
+				t.Fatalf("got error %v (%T), want a *NetworkError", err, err)
+			}
+			if netErr.Kind != NetworkErrorConnection {
+				t.Errorf("Kind = %q, want %q", netErr.Kind, NetworkErrorConnection)
+			}
+			if netErr.Endpoint != endpoint {
+				t.Errorf("Endpoint = %q, want %q", netErr.Endpoint, endpoint)
+			}
+		})
+	}
+}
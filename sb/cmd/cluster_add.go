@@ -70,17 +70,6 @@ func execute(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
 		return
 	}
-	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/clusters/", sbUrl), nil)
-	req.Header.Add("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-
-	if resp.StatusCode == http.StatusNotFound {
-		fmt.Println("This instance cannot manage clusters.")
-		return
-	}
-
 	// Prompt for cluster name
 	cluster.Name = prompt("Enter the cluster name", true)
 
@@ -110,46 +99,52 @@ func execute(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	if err := postCluster(sbUrl, token, cluster); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("New cluster created successfully.")
+
+	// TODO: print tekton logs here.
+}
+
+// postCluster asks the server to create cluster.
+func postCluster(sbUrl, token string, cluster Cluster) error {
 	jsonData, err := json.Marshal(cluster)
 	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
-		return
+		return fmt.Errorf("error marshaling JSON: %w", err)
 	}
 
 	fullUrl := sbUrl + "/api/clusters/"
 
-	req, err = http.NewRequest("POST", fullUrl, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", fullUrl, bytes.NewBuffer(jsonData))
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 
 	// Set the necessary headers
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	// Send the request using the default client
-	client = &http.Client{}
-	resp, err = client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
-
 	defer resp.Body.Close() // Ensure the response body is closed
 
 	// Check the status code of the response
-	if resp.StatusCode == http.StatusCreated {
-		fmt.Println("New cluster created successfully.")
-	} else if resp.StatusCode == http.StatusUnauthorized {
-		fmt.Println("Authorization failed. Check your token.")
-	} else if resp.StatusCode == http.StatusBadRequest {
-		fmt.Println("Unable to create cluster, bad request.")
-	} else if resp.StatusCode == http.StatusInternalServerError {
-		fmt.Println("Unable to create cluster, internal server error.")
-	} else {
-		fmt.Printf("Unexpected status code: %d\n", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+	case http.StatusUnauthorized:
+		return fmt.Errorf("Authorization failed. Check your token.")
+	case http.StatusBadRequest:
+		return fmt.Errorf("Unable to create cluster, bad request.")
+	case http.StatusInternalServerError:
+		return fmt.Errorf("Unable to create cluster, internal server error.")
+	default:
+		return fmt.Errorf("Unexpected status code: %d", resp.StatusCode)
 	}
-
-	// TODO: print tekton logs here.
 }
 
 func prompt(label string, required bool) string {
@@ -181,7 +176,12 @@ func fetchAndSelectRegion(cloud string) string {
 		return cloud
 	}
 	url := fmt.Sprintf("%s/api/providers/region-choices/%s/", sbUrl, cloud)
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		fmt.Println("Failed to create request:", err)
+		return ""
+	}
+	resp, err := doRequest(req)
 	if err != nil {
 		fmt.Println("Failed to fetch regions:", err)
 		return ""
@@ -254,7 +254,12 @@ func fetchNodeSizes(cloud string) [][]string {
 		return nil
 	}
 	url := fmt.Sprintf("%s/api/providers/size-choices/%s/", sbUrl, cloud)
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		fmt.Println("Failed to create request:", err)
+		return nil
+	}
+	resp, err := doRequest(req)
 	if err != nil {
 		fmt.Println("Failed to fetch regions:", err)
 		return nil
@@ -21,7 +21,6 @@ func clusterDelete(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
 		return
 	}
-	client := &http.Client{}
 	clusters, err := fetchClusters()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error fetching clusters: %v\n", err)
@@ -50,9 +49,10 @@ func clusterDelete(cmd *cobra.Command, args []string) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
 
 	defer resp.Body.Close()
@@ -45,18 +45,16 @@ func fetchClusters() ([]ClusterDetail, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("this instance cannot manage clusters")
-	}
-
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("this instance cannot manage clusters")
+	}
+
 	var clusters []ClusterDetail
 	if err := json.NewDecoder(resp.Body).Decode(&clusters); err != nil {
 		return nil, err
@@ -103,9 +101,8 @@ func checkClusterStatus(clusterUUID, sbUrl string, timeout, interval time.Durati
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	// Send the request
-	client := &http.Client{}
 	startTime := time.Now()
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -139,9 +136,10 @@ func checkClusterStatus(clusterUUID, sbUrl string, timeout, interval time.Durati
 }
 
 var clustersCmd = &cobra.Command{
-	Use:     "clusters",
-	Aliases: []string{"cluster"},
-	Short:   "Manage clusters",
+	Use:              "clusters",
+	Aliases:          []string{"cluster"},
+	Short:            "Manage clusters",
+	PersistentPreRun: requireFeature(FeatureClusters, "This instance cannot manage clusters."),
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
 	},
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shapeblock/sb-cli/sb/config"
+)
+
+// Range of server versions this CLI is known to work with. The upper bound
+// is exclusive.
+const (
+	minServerVersion = "1.0.0"
+	maxServerVersion = "2.0.0"
+)
+
+// parseVersion parses versions like "v1.2.3" or "1.2" into their numeric
+// parts, ignoring any pre-release or build suffix.
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	fields := strings.Split(version, ".")
+	if version == "" || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// compareVersions returns -1, 0 or 1 depending on whether a is lower, equal
+// or higher than b. Both versions must be parseable.
+func compareVersions(a, b string) int {
+	va, _ := parseVersion(a)
+	vb, _ := parseVersion(b)
+	for i := range va {
+		if va[i] < vb[i] {
+			return -1
+		}
+		if va[i] > vb[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkCompatibility reports whether this CLI supports the server described
+// by caps. The reason explains the verdict and is never empty.
+func checkCompatibility(caps Capabilities) (bool, string) {
+	if _, ok := parseVersion(caps.Version); !ok {
+		return true, "server does not report a version, compatibility unknown"
+	}
+
+	if compareVersions(caps.Version, minServerVersion) < 0 || compareVersions(caps.Version, maxServerVersion) >= 0 {
+		return false, fmt.Sprintf("server version %s is outside the supported range %s to %s", caps.Version, minServerVersion, maxServerVersion)
+	}
+
+	clientVersion := config.GetVersion()
+	if _, ok := parseVersion(clientVersion); ok && caps.MinCLIVersion != "" {
+		if _, ok := parseVersion(caps.MinCLIVersion); ok && compareVersions(clientVersion, caps.MinCLIVersion) < 0 {
+			return false, fmt.Sprintf("server requires sb-cli %s or newer", caps.MinCLIVersion)
+		}
+	}
+
+	return true, fmt.Sprintf("server version %s is supported", caps.Version)
+}
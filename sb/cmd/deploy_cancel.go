@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var deployCancelCmd = &cobra.Command{
+	Use:   "cancel [UUID]",
+	Short: "Cancel a running deployment",
+	Long: `Asks the server to abort a running deployment and its pipeline run. Without a
+UUID, the newest unfinished deployment of the app is cancelled.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  deployCancel,
+}
+
+var (
+	cancelApp string
+	cancelYes bool
+)
+
+func deployCancel(cmd *cobra.Command, args []string) {
+	app, err := resolveApp(cancelApp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	deployments, err := fetchDeployments(app.UUID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching deployments: %v\n", err)
+		os.Exit(1)
+	}
+
+	var target Deployment
+	for _, deployment := range deployments {
+		if len(args) == 1 && deployment.UUID == args[0] {
+			target = deployment
+			break
+		}
+		if len(args) == 0 && !deploymentFinished(deployment.Status) {
+			target = deployment
+			break
+		}
+	}
+	switch {
+	case target.UUID == "" && len(args) == 1:
+		fmt.Fprintf(os.Stderr, "Deployment %s not found for app %s.\n", args[0], app.Name)
+		os.Exit(1)
+	case target.UUID == "":
+		fmt.Fprintf(os.Stderr, "App %s has no running deployment.\n", app.Name)
+		os.Exit(1)
+	case deploymentFinished(target.Status):
+		fmt.Fprintf(os.Stderr, "Deployment %s already finished with status %s.\n", target.UUID, target.Status)
+		os.Exit(1)
+	}
+
+	if !cancelYes {
+		confirmationPrompt := promptui.Prompt{
+			Label:     fmt.Sprintf("Cancel deployment %s of %s (%s)", target.UUID, app.Name, target.Status),
+			IsConfirm: true,
+		}
+		if _, err := confirmationPrompt.Run(); err != nil {
+			fmt.Println("Deployment not cancelled.")
+			os.Exit(1)
+		}
+	}
+
+	sbUrl, token, _, err := getContext()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
+		os.Exit(1)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/deployments/%s/cancel/", sbUrl, target.UUID), nil)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := doRequest(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error cancelling deployment: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+		fmt.Println("Cancellation requested.")
+	case http.StatusUnauthorized:
+		fmt.Println("Authorization failed. Check your token.")
+		os.Exit(1)
+	case http.StatusNotFound:
+		fmt.Println("Deployment not found.")
+		os.Exit(1)
+	case http.StatusConflict:
+		fmt.Println("Deployment already finished.")
+		os.Exit(1)
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Unexpected status code: %d %s\n", resp.StatusCode, body)
+		os.Exit(1)
+	}
+
+	// The pipeline run takes a moment to stop.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	deployment, err := waitForDeployment(ctx, app.UUID, target.UUID)
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Printf("Deployment %s is still %s, check again with 'sb-cli apps deploy show %s'.\n", target.UUID, deployment.Status, target.UUID)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching deployment status: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Deployment %s is %s.\n", deployment.UUID, deployment.Status)
+	if deployment.Status != deploymentCancelled {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	deployCmd.AddCommand(deployCancelCmd)
+	deployCancelCmd.Flags().StringVar(&cancelApp, "app", "", "App name or UUID")
+	deployCancelCmd.Flags().BoolVarP(&cancelYes, "yes", "y", false, "Skip the confirmation prompt")
+}
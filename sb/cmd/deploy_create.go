@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -35,7 +37,16 @@ type DeploymentResponse struct {
 	UUID string `json:"uuid"`
 }
 
-var follow bool
+var (
+	follow    bool
+	deployRef string
+	setRef    bool
+	fromDir   string
+
+	waitDeploy    bool
+	deployTimeout time.Duration
+	deployNotify  []string
+)
 
 func createDeployment(cmd *cobra.Command, args []string) {
 	apps, err := fetchApps()
@@ -44,6 +55,21 @@ func createDeployment(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if setRef && deployRef == "" {
+		fmt.Fprintln(os.Stderr, "--set-ref requires --ref")
+		os.Exit(1)
+	}
+	if fromDir != "" && deployRef != "" {
+		fmt.Fprintln(os.Stderr, "--from-dir and --ref cannot be used together")
+		os.Exit(1)
+	}
+
+	targets, err := notifyTargets(deployNotify)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	app := selectApp(apps)
 
 	// API call
@@ -52,99 +78,198 @@ func createDeployment(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
 		return
 	}
-	fullUrl := fmt.Sprintf("%s/api/apps/%s/deployments/", sbUrl, app.UUID)
 
-	req, err := http.NewRequest("POST", fullUrl, nil)
+	if fromDir != "" {
+		deploymentResponse, err := uploadSource(sbUrl, token, app.UUID, fromDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error uploading source: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Deployment created successfully.")
+		finishDeployment(sbUrl, token, app, deploymentResponse.UUID, follow, waitDeploy || notifyRequested(deployNotify), deployTimeout, targets)
+		return
+	}
+
+	if setRef {
+		if err := updateAppRef(sbUrl, token, app.UUID, deployRef); err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating app ref: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("App %s now deploys %s by default.\n", app.Name, deployRef)
+	}
+
+	deploymentResponse, err := postDeployment(sbUrl, token, app.UUID, deployRef)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintf(os.Stderr, "Error creating deployment: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Deployment created successfully.")
+
+	finishDeployment(sbUrl, token, app, deploymentResponse.UUID, follow, waitDeploy || notifyRequested(deployNotify), deployTimeout, targets)
+}
+
+// postDeployment starts a deployment of ref, or of the app's configured ref
+// if ref is empty.
+func postDeployment(sbUrl, token, appUUID, ref string) (DeploymentResponse, error) {
+	// Without a body the server builds the app's configured ref.
+	var body io.Reader
+	if ref != "" {
+		jsonData, err := json.Marshal(map[string]string{"ref": ref})
+		if err != nil {
+			return DeploymentResponse{}, err
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	fullUrl := fmt.Sprintf("%s/api/apps/%s/deployments/", sbUrl, appUUID)
+
+	req, err := http.NewRequest("POST", fullUrl, body)
+	if err != nil {
+		return DeploymentResponse{}, err
 	}
 
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
-		fmt.Println(err)
+		return DeploymentResponse{}, err
 	}
-
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusCreated {
-		fmt.Println("Deployment created successfully.")
-	} else if resp.StatusCode == http.StatusUnauthorized {
-		fmt.Println("Authorization failed. Check your token.")
-		os.Exit(1)
-	} else if resp.StatusCode == http.StatusBadRequest {
-		fmt.Println("Unable to create deployment, bad request.")
-		os.Exit(1)
-	} else if resp.StatusCode == http.StatusInternalServerError {
-		fmt.Println("Unable to create deployment, internal server error.")
-		os.Exit(1)
-	} else {
-		fmt.Printf("Unexpected status code: %d\n", resp.StatusCode)
-		os.Exit(1)
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusUnauthorized:
+		return DeploymentResponse{}, fmt.Errorf("authorization failed, check your token")
+	case http.StatusBadRequest:
+		return DeploymentResponse{}, fmt.Errorf("bad request")
+	case http.StatusInternalServerError:
+		return DeploymentResponse{}, fmt.Errorf("internal server error")
+	default:
+		return DeploymentResponse{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
 	var deploymentResponse DeploymentResponse
-	//fmt.Print(deploymentResponse)
 	if err := json.NewDecoder(resp.Body).Decode(&deploymentResponse); err != nil {
-		fmt.Printf("Unable to decode deployment response: %v\n", err)
-		os.Exit(1)
+		return DeploymentResponse{}, fmt.Errorf("unable to decode deployment response: %v", err)
 	}
+	return deploymentResponse, nil
+}
 
-	if follow {
-		const maxAttempts = 10
-		const delay = 10 * time.Second
-
-		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond) // Initialize spinner
-		s.Start()                                                    // Start spinner
+// updateAppRef changes the ref the app builds by default.
+func updateAppRef(sbUrl, token, appUUID, ref string) error {
+	jsonData, err := json.Marshal(map[string]string{"ref": ref})
+	if err != nil {
+		return err
+	}
 
-		for attempt := 1; attempt <= maxAttempts; attempt++ {
-			fullUrl = fmt.Sprintf("%s/deployments/%s/pod-info/", sbUrl, deploymentResponse.UUID)
+	req, err := http.NewRequest("PATCH", fmt.Sprintf("%s/api/apps/%s/", sbUrl, appUUID), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-			req, err = http.NewRequest("GET", fullUrl, nil)
-			if err != nil {
-				fmt.Println(err)
-			}
+	resp, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-			req.Header.Add("Content-Type", "application/json")
-			req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
 
-			client = &http.Client{}
-			resp, err := client.Do(req)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
+// followDeployment tails the build pod logs of a deployment until the pod
+// completes. It returns an error wrapping errBuildFailed if the build pod
+// fails. Until the build pod is ready it keeps retrying, for as long as ctx
+// allows.
+func followDeployment(ctx context.Context, sbUrl, token, deploymentUUID string) error {
+	const delay = 10 * time.Second
 
-			defer resp.Body.Close()
-			var podInfo PodInfo
-			if err := json.NewDecoder(resp.Body).Decode(&podInfo); err != nil {
-				fmt.Printf("Unable to decode podinfo from response: %v\n", err)
-				os.Exit(1)
-			}
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond) // Initialize spinner
+	s.Start()                                                    // Start spinner
+	defer s.Stop()
 
+	var lastErr error
+	for {
+		podInfo, ready, err := fetchPodInfo(ctx, sbUrl, token, deploymentUUID)
+		switch {
+		case err != nil:
+			return err
+		case ready:
 			decodedKubeConfig, err := base64.StdEncoding.DecodeString(podInfo.KubeConfig)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to decode kubeconfig: %v\n", err)
-				os.Exit(1)
+				return fmt.Errorf("failed to decode kubeconfig: %v", err)
 			}
-			if err := tailPodLogs(podInfo.Name, string(decodedKubeConfig), podInfo.Namespace); err != nil {
-				// fmt.Fprintf(os.Stderr, "Failed to tail logs: %v\n", err)
-				time.Sleep(delay)
-				continue
+			lastErr = tailPodLogs(ctx, podInfo.Name, string(decodedKubeConfig), podInfo.Namespace)
+			if lastErr == nil || errors.Is(lastErr, errBuildFailed) {
+				return lastErr
 			}
-			break
+		default:
+			// A deployment that ended without a build pod has nothing to
+			// follow.
+			if deployment, err := fetchDeployment(deploymentUUID); err == nil && deploymentFinished(deployment.Status) {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("unable to follow the build logs: %w (last error: %v)", ctx.Err(), lastErr)
+			}
+			return fmt.Errorf("build pod did not become ready: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// fetchPodInfo returns the build pod of a deployment. ready is false while
+// the server has no pod to report yet.
+func fetchPodInfo(ctx context.Context, sbUrl, token, deploymentUUID string) (PodInfo, bool, error) {
+	fullUrl := fmt.Sprintf("%s/deployments/%s/pod-info/", sbUrl, deploymentUUID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fullUrl, nil)
+	if err != nil {
+		return PodInfo{}, false, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := doRequest(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return PodInfo{}, false, ctx.Err()
 		}
+		return PodInfo{}, false, err
+	}
+	defer resp.Body.Close()
 
-		s.Stop() // Stop spinner
-		// TODO: check on Helm status
+	switch {
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusAccepted,
+		resp.StatusCode == http.StatusNoContent, resp.StatusCode >= 500:
+		return PodInfo{}, false, nil
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(resp.Body)
+		return PodInfo{}, false, fmt.Errorf("unexpected status code %d fetching pod info: %s", resp.StatusCode, body)
 	}
 
+	var podInfo PodInfo
+	if err := json.NewDecoder(resp.Body).Decode(&podInfo); err != nil {
+		return PodInfo{}, false, fmt.Errorf("unable to decode podinfo from response: %v", err)
+	}
+	if podInfo.Name == "" || podInfo.KubeConfig == "" {
+		return PodInfo{}, false, nil
+	}
+	return podInfo, true, nil
 }
 
-func tailPodLogs(podName, kubeConfig, namespace string) error {
+func tailPodLogs(ctx context.Context, podName, kubeConfig, namespace string) error {
 	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeConfig))
 	if err != nil {
 		return err
@@ -155,48 +280,67 @@ func tailPodLogs(podName, kubeConfig, namespace string) error {
 		return err
 	}
 
-	pod, err := clientset.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
 
-	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
-		if err := streamLogsWithRetry(clientset, namespace, podName, container.Name); err != nil {
+	for i, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		printStepHeader(container.Name, i < len(pod.Spec.InitContainers))
+		if err := streamLogsWithRetry(ctx, clientset, namespace, podName, container.Name); err != nil {
 			return err
 		}
 	}
 
 	for {
-		pod, err := clientset.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
 
 		if pod.Status.Phase == corev1.PodSucceeded {
 			fmt.Println("Pod has completed.")
-			break
+			return nil
+		}
+		if err := podFailure(pod); err != nil {
+			return fmt.Errorf("%w: %v", errBuildFailed, err)
 		}
-		time.Sleep(5 * time.Second)
-	}
 
-	return nil
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
 }
 
-func streamLogsWithRetry(clientset *kubernetes.Clientset, namespace, podName, containerName string) error {
+// streamLogsWithRetry keeps streaming a container's logs until the stream
+// ends cleanly, giving up once the pod has failed.
+func streamLogsWithRetry(ctx context.Context, clientset *kubernetes.Clientset, namespace, podName, containerName string) error {
 	for {
-		err := streamLogs(clientset, namespace, podName, containerName)
+		err := streamLogs(ctx, clientset, namespace, podName, containerName)
 		if err == nil {
-			break
+			return nil
+		}
+
+		pod, getErr := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if getErr == nil {
+			if failure := podFailure(pod); failure != nil {
+				return fmt.Errorf("%w: %v", errBuildFailed, failure)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
 		}
-		// fmt.Printf("Retrying log stream for container %s in pod %s: %v\n", containerName, podName, err)
-		time.Sleep(5 * time.Second)
 	}
-	return nil
 }
 
-func streamLogs(clientset *kubernetes.Clientset, namespace, podName, containerName string) error {
+func streamLogs(ctx context.Context, clientset *kubernetes.Clientset, namespace, podName, containerName string) error {
 	req := clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{Container: containerName, Follow: true})
-	stream, err := req.Stream(context.Background())
+	stream, err := req.Stream(ctx)
 	if err != nil {
 		return err
 	}
@@ -209,4 +353,10 @@ func streamLogs(clientset *kubernetes.Clientset, namespace, podName, containerNa
 func init() {
 	deployCmd.AddCommand(createDeployCmd)
 	createDeployCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Follow the pod logs")
+	createDeployCmd.Flags().BoolVarP(&waitDeploy, "wait", "w", false, "Wait until the deployment finishes and exit non-zero unless it succeeded")
+	createDeployCmd.Flags().DurationVar(&deployTimeout, "timeout", 30*time.Minute, "How long --follow and --wait wait for the deployment")
+	createDeployCmd.Flags().StringArrayVar(&deployNotify, "notify", nil, "Notify slack=URL, webhook=URL or desktop when the deployment ends; implies --wait (repeatable). The context's notify list is used with --wait or --follow unless this is none")
+	createDeployCmd.Flags().StringVar(&deployRef, "ref", "", "Tag, branch or commit SHA to deploy instead of the app's ref")
+	createDeployCmd.Flags().StringVar(&fromDir, "from-dir", "", "Build from a local directory instead of git, honouring .gitignore and .sbignore")
+	createDeployCmd.Flags().BoolVar(&setRef, "set-ref", false, "Also make --ref the app's default ref")
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var deployListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the deployments of an app, newest first",
+	Run:   deployList,
+}
+
+var deployListApp string
+
+func deployList(cmd *cobra.Command, args []string) {
+	app, err := resolveApp(deployListApp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	deployments, err := fetchDeployments(app.UUID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching deployments: %v\n", err)
+		os.Exit(1)
+	}
+	if len(deployments) == 0 {
+		fmt.Printf("No deployments found for app %s.\n", app.Name)
+		return
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetStyle(table.StyleLight)
+	t.AppendHeader(table.Row{"UUID", "Status", "Ref", "Trigger", "Started", "Finished", "Duration"})
+	for _, deployment := range deployments {
+		duration := "-"
+		if d := deployment.Duration(); d > 0 {
+			duration = d.String()
+		}
+		t.AppendRow(table.Row{
+			deployment.UUID,
+			deployment.Status,
+			deployment.Revision(),
+			deployment.TriggerInfo(),
+			formatTimestamp(deployment.StartedAt),
+			formatTimestamp(deployment.FinishedAt),
+			duration,
+		})
+	}
+	t.Render()
+}
+
+func init() {
+	deployCmd.AddCommand(deployListCmd)
+	deployListCmd.Flags().StringVar(&deployListApp, "app", "", "App name or UUID")
+}
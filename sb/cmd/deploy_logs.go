@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var deployLogsCmd = &cobra.Command{
+	Use:   "logs [UUID]",
+	Short: "Show the build logs of a deployment",
+	Long: `Prints the stored build logs of a deployment, one section per pipeline step.
+Logs of a running deployment are streamed live. Without a UUID, the newest
+deployment of the app is used.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  deployLogs,
+}
+
+var deployLogsApp string
+
+// DeploymentLogStep is the stored output of one container of the build pod.
+type DeploymentLogStep struct {
+	Name string `json:"name"`
+	Init bool   `json:"init"`
+	Log  string `json:"log"`
+}
+
+func deployLogs(cmd *cobra.Command, args []string) {
+	var deployment Deployment
+	var err error
+	if len(args) == 1 {
+		deployment, err = fetchDeployment(args[0])
+	} else {
+		deployment, err = latestDeployment(deployLogsApp)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	sbUrl, token, _, err := getContext()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !deploymentFinished(deployment.Status) {
+		fmt.Printf("Deployment %s is %s, streaming logs.\n", deployment.UUID, deployment.Status)
+		if err := followDeployment(context.Background(), sbUrl, token, deployment.UUID); err != nil {
+			fmt.Fprintf(os.Stderr, "Error streaming logs: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	steps, err := fetchDeploymentLogs(sbUrl, token, deployment.UUID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching logs: %v\n", err)
+		os.Exit(1)
+	}
+	for _, step := range steps {
+		printStepHeader(step.Name, step.Init)
+		fmt.Print(step.Log)
+		if len(step.Log) > 0 && step.Log[len(step.Log)-1] != '\n' {
+			fmt.Println()
+		}
+	}
+}
+
+// latestDeployment returns the newest deployment of an app.
+func latestDeployment(appName string) (Deployment, error) {
+	app, err := resolveApp(appName)
+	if err != nil {
+		return Deployment{}, err
+	}
+	deployments, err := fetchDeployments(app.UUID)
+	if err != nil {
+		return Deployment{}, err
+	}
+	if len(deployments) == 0 {
+		return Deployment{}, fmt.Errorf("app %s has no deployments", app.Name)
+	}
+	return deployments[0], nil
+}
+
+func fetchDeploymentLogs(sbUrl, token, deploymentUUID string) ([]DeploymentLogStep, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/deployments/%s/logs/", sbUrl, deploymentUUID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("no stored logs for deployment %s", deploymentUUID)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var steps []DeploymentLogStep
+	if err := json.NewDecoder(resp.Body).Decode(&steps); err != nil {
+		return nil, fmt.Errorf("failed to decode logs: %v", err)
+	}
+	return steps, nil
+}
+
+// printStepHeader separates the output of the build pod's containers.
+func printStepHeader(name string, init bool) {
+	if init {
+		name = "init: " + name
+	}
+	fmt.Printf("\n==> %s <==\n", name)
+}
+
+func init() {
+	deployCmd.AddCommand(deployLogsCmd)
+	deployLogsCmd.Flags().StringVar(&deployLogsApp, "app", "", "App name or UUID, used when no UUID is given")
+}
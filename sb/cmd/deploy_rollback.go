@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var deployRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Redeploy a previous successful deployment",
+	Long: `Redeploys the image of a previous successful deployment. The current release
+is the newest successful deployment; without --to, the successful deployment
+before it is used.`,
+	Run: deployRollback,
+}
+
+var (
+	rollbackApp     string
+	rollbackTo      string
+	rollbackYes     bool
+	rollbackFollow  bool
+	rollbackWait    bool
+	rollbackTimeout time.Duration
+	rollbackNotify  []string
+)
+
+func deployRollback(cmd *cobra.Command, args []string) {
+	targets, err := notifyTargets(rollbackNotify)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	app, err := resolveApp(rollbackApp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	deployments, err := fetchDeployments(app.UUID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching deployments: %v\n", err)
+		os.Exit(1)
+	}
+
+	target, err := rollbackTarget(deployments, rollbackTo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !rollbackYes {
+		confirmationPrompt := promptui.Prompt{
+			Label:     fmt.Sprintf("Roll back %s to deployment %s (%s)", app.Name, target.UUID, target.Revision()),
+			IsConfirm: true,
+		}
+		if _, err := confirmationPrompt.Run(); err != nil {
+			fmt.Println("Rollback cancelled.")
+			os.Exit(1)
+		}
+	}
+
+	sbUrl, token, _, err := getContext()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
+		os.Exit(1)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/deployments/%s/rollback/", sbUrl, target.UUID), nil)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := doRequest(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating rollback: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusCreated {
+		fmt.Println("Rollback deployment created successfully.")
+	} else if resp.StatusCode == http.StatusUnauthorized {
+		fmt.Println("Authorization failed. Check your token.")
+		os.Exit(1)
+	} else if resp.StatusCode == http.StatusBadRequest {
+		fmt.Println("Unable to roll back, bad request.")
+		os.Exit(1)
+	} else if resp.StatusCode == http.StatusNotFound {
+		fmt.Println("Deployment not found.")
+		os.Exit(1)
+	} else {
+		fmt.Printf("Unexpected status code: %d\n", resp.StatusCode)
+		os.Exit(1)
+	}
+
+	var deploymentResponse DeploymentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&deploymentResponse); err != nil {
+		fmt.Printf("Unable to decode deployment response: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Deployment UUID: %s\n", deploymentResponse.UUID)
+
+	// The exit status of a followed rollback reflects its result.
+	finishDeployment(sbUrl, token, app, deploymentResponse.UUID, rollbackFollow, rollbackFollow || rollbackWait || notifyRequested(rollbackNotify), rollbackTimeout, targets)
+}
+
+// rollbackTarget picks the deployment to roll back to. deployments must be
+// sorted newest first; the newest one is treated as the current deployment.
+// rollbackTarget picks the deployment to roll back to. The newest successful
+// deployment is the release that is live, as failed or unfinished ones never
+// replaced it, so by default the successful deployment before it is used.
+func rollbackTarget(deployments []Deployment, uuid string) (Deployment, error) {
+	current := -1
+	for i, deployment := range deployments {
+		if deployment.Status == deploymentSuccess {
+			current = i
+			break
+		}
+	}
+
+	if uuid != "" {
+		for i, deployment := range deployments {
+			if deployment.UUID != uuid {
+				continue
+			}
+			if deployment.Status != deploymentSuccess {
+				return Deployment{}, fmt.Errorf("deployment %s did not succeed (status %s)", uuid, deployment.Status)
+			}
+			if i == current {
+				return Deployment{}, fmt.Errorf("deployment %s is the current release", uuid)
+			}
+			return deployment, nil
+		}
+		return Deployment{}, fmt.Errorf("deployment %s not found", uuid)
+	}
+
+	if current >= 0 {
+		for _, deployment := range deployments[current+1:] {
+			if deployment.Status == deploymentSuccess {
+				return deployment, nil
+			}
+		}
+	}
+	return Deployment{}, fmt.Errorf("no successful deployment before the current one")
+}
+
+func init() {
+	deployCmd.AddCommand(deployRollbackCmd)
+	deployRollbackCmd.Flags().StringVar(&rollbackApp, "app", "", "App name or UUID")
+	deployRollbackCmd.Flags().StringVar(&rollbackTo, "to", "", "UUID of the deployment to roll back to")
+	deployRollbackCmd.Flags().BoolVarP(&rollbackYes, "yes", "y", false, "Skip the confirmation prompt")
+	deployRollbackCmd.Flags().BoolVarP(&rollbackFollow, "follow", "f", false, "Follow the pod logs")
+	deployRollbackCmd.Flags().BoolVarP(&rollbackWait, "wait", "w", false, "Wait until the rollback finishes and exit non-zero unless it succeeded")
+	deployRollbackCmd.Flags().StringArrayVar(&rollbackNotify, "notify", nil, "Notify slack=URL, webhook=URL or desktop when the rollback ends; implies --wait (repeatable). The context's notify list is used with --wait or --follow unless this is none")
+	deployRollbackCmd.Flags().DurationVar(&rollbackTimeout, "timeout", 30*time.Minute, "How long --follow and --wait wait for the rollback")
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var deployShowCmd = &cobra.Command{
+	Use:   "show UUID",
+	Short: "Show the details of a deployment",
+	Args:  cobra.ExactArgs(1),
+	Run:   deployShow,
+}
+
+func deployShow(cmd *cobra.Command, args []string) {
+	deployment, err := fetchDeployment(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching deployment: %v\n", err)
+		os.Exit(1)
+	}
+
+	duration := "-"
+	if d := deployment.Duration(); d > 0 {
+		duration = d.String()
+	}
+	commit := deployment.CommitSHA
+	if commit == "" {
+		commit = "-"
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetStyle(table.StyleBold)
+	t.AppendHeader(table.Row{"Deployment"})
+	t.AppendRows([]table.Row{
+		{fmt.Sprintf("ID: %s", deployment.UUID)},
+		{fmt.Sprintf("Status: %s", deployment.Status)},
+		{fmt.Sprintf("Ref: %s", deployment.Ref)},
+		{fmt.Sprintf("Commit: %s", commit)},
+		{fmt.Sprintf("Trigger: %s", deployment.TriggerInfo())},
+		{fmt.Sprintf("Created: %s", formatTimestamp(deployment.CreatedAt))},
+		{fmt.Sprintf("Started: %s", formatTimestamp(deployment.StartedAt))},
+		{fmt.Sprintf("Finished: %s", formatTimestamp(deployment.FinishedAt))},
+		{fmt.Sprintf("Duration: %s", duration)},
+	})
+	t.Render()
+}
+
+func init() {
+	deployCmd.AddCommand(deployShowCmd)
+}
@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one line of a .gitignore or .sbignore file. base is the
+// slash separated directory the file lives in, relative to the source root.
+type ignoreRule struct {
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher applies gitignore rules in file order; the last matching
+// rule wins.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// load reads the ignore files of dir, which is relative to root.
+func (m *ignoreMatcher) load(root, dir string) error {
+	for _, name := range []string{".gitignore", ".sbignore"} {
+		file, err := os.Open(filepath.Join(root, filepath.FromSlash(dir), name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+				m.rules = append(m.rules, rule)
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		name := rel
+		if rule.base != "." {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if rule.re.MatchString(name) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, "\\")
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// Patterns without a slash match at any depth, others are anchored.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	var expr strings.Builder
+	if !anchored {
+		expr.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '*':
+			if strings.HasPrefix(line[i:], "**/") {
+				expr.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(line[i:], "**") {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				expr.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := line[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				// Like wildcards, negated classes never match a slash.
+				class = "^/" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	re, err := regexp.Compile("^" + expr.String() + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// packSource writes a gzipped tarball of dir to w, skipping .git and
+// everything matched by .gitignore and .sbignore files.
+func packSource(dir string, w io.Writer) (int, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	matcher := &ignoreMatcher{}
+	count := 0
+
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel == "." {
+			return matcher.load(dir, rel)
+		}
+		if info.IsDir() && path.Base(rel) == ".git" {
+			return filepath.SkipDir
+		}
+		if matcher.ignored(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if err := matcher.load(dir, rel); err != nil {
+				return err
+			}
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() && info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(tw, f); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	return count, gz.Close()
+}
+
+// progressReader prints upload progress to stderr as the body is read.
+type progressReader struct {
+	r     io.Reader
+	total int64
+	read  int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		percent := 100
+		if p.total > 0 {
+			percent = int(p.read * 100 / p.total)
+		}
+		fmt.Fprintf(os.Stderr, "\rUploading %s / %s (%d%%)", formatBytes(p.read), formatBytes(p.total), percent)
+	}
+	if err == io.EOF {
+		fmt.Fprintln(os.Stderr)
+	}
+	return n, err
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// uploadSource packs dir and uploads it as the source of a new deployment.
+func uploadSource(sbUrl, token, appUUID, dir string) (DeploymentResponse, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return DeploymentResponse{}, err
+	}
+	if !info.IsDir() {
+		return DeploymentResponse{}, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	tarball, err := os.CreateTemp("", "sb-source-*.tar.gz")
+	if err != nil {
+		return DeploymentResponse{}, err
+	}
+	defer os.Remove(tarball.Name())
+	defer tarball.Close()
+
+	count, err := packSource(dir, tarball)
+	if err != nil {
+		return DeploymentResponse{}, fmt.Errorf("failed to package %s: %w", dir, err)
+	}
+	size, err := tarball.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return DeploymentResponse{}, err
+	}
+	if _, err := tarball.Seek(0, io.SeekStart); err != nil {
+		return DeploymentResponse{}, err
+	}
+	fmt.Printf("Packaged %d files from %s.\n", count, dir)
+
+	fullUrl := fmt.Sprintf("%s/api/apps/%s/deployments/upload/", sbUrl, appUUID)
+	req, err := http.NewRequest("POST", fullUrl, &progressReader{r: tarball, total: size})
+	if err != nil {
+		return DeploymentResponse{}, err
+	}
+	req.ContentLength = size
+	req.Header.Add("Content-Type", "application/gzip")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return DeploymentResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return DeploymentResponse{}, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+	}
+
+	var deploymentResponse DeploymentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&deploymentResponse); err != nil {
+		return DeploymentResponse{}, fmt.Errorf("failed to decode deployment response: %v", err)
+	}
+	return deploymentResponse, nil
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{"name at top level", []string{"secret.txt"}, "secret.txt", false, true},
+		{"name at any depth", []string{"secret.txt"}, "a/b/secret.txt", false, true},
+		{"wildcard", []string{"*.log"}, "logs/app.log", false, true},
+		{"wildcard does not cross slashes", []string{"a*b"}, "a/b", false, false},
+		{"question mark", []string{"file?.txt"}, "file1.txt", false, true},
+		{"question mark does not match slash", []string{"a?b"}, "a/b", false, false},
+		{"comment", []string{"#secret.txt"}, "#secret.txt", false, false},
+		{"escaped hash", []string{`\#secret.txt`}, "#secret.txt", false, true},
+		{"trailing spaces", []string{"secret.txt  "}, "secret.txt", false, true},
+
+		{"leading slash anchors", []string{"/build"}, "build", true, true},
+		{"leading slash not nested", []string{"/build"}, "src/build", true, false},
+		{"middle slash anchors", []string{"src/gen"}, "src/gen", true, true},
+		{"middle slash not nested", []string{"src/gen"}, "app/src/gen", true, false},
+
+		{"leading ** at top level", []string{"**/cache"}, "cache", true, true},
+		{"leading ** nested", []string{"**/cache"}, "a/b/cache", true, true},
+		{"leading ** with path", []string{"**/tmp/out"}, "x/tmp/out", false, true},
+		{"trailing ** matches contents", []string{"dist/**"}, "dist/js/app.js", false, true},
+		{"trailing ** is anchored", []string{"dist/**"}, "src/dist/app.js", false, false},
+		{"trailing ** not the directory itself", []string{"dist/**"}, "dist", true, false},
+		{"middle ** with no directories", []string{"a/**/b"}, "a/b", false, true},
+		{"middle ** with directories", []string{"a/**/b"}, "a/x/y/b", false, true},
+
+		{"negation after ignore", []string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{"negation keeps other matches", []string{"*.log", "!keep.log"}, "drop.log", false, true},
+		{"ignore after negation", []string{"!keep.log", "*.log"}, "keep.log", false, true},
+		{"escaped exclamation mark", []string{`\!important`}, "!important", false, true},
+
+		{"directory rule on directory", []string{"build/"}, "build", true, true},
+		{"directory rule on file", []string{"build/"}, "build", false, false},
+		{"directory rule nested", []string{"build/"}, "src/build", true, true},
+		{"anchored directory rule on file", []string{"/out/"}, "out", false, false},
+
+		{"class", []string{"file[0-9].txt"}, "file3.txt", false, true},
+		{"class no match", []string{"file[0-9].txt"}, "filex.txt", false, false},
+		{"negated class", []string{"file[!0-9].txt"}, "filex.txt", false, true},
+		{"negated class no match", []string{"file[!0-9].txt"}, "file3.txt", false, false},
+		{"negated class does not match slash", []string{"a[!x]b"}, "a/b", false, false},
+		{"unterminated class is literal", []string{"file[.txt"}, "file[.txt", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher := &ignoreMatcher{}
+			for _, pattern := range tt.patterns {
+				if rule, ok := parseIgnoreRule(".", pattern); ok {
+					matcher.rules = append(matcher.rules, rule)
+				}
+			}
+			if got := matcher.ignored(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("ignored(%q, %v) with %q = %v, want %v", tt.path, tt.isDir, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackSourceIgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":              "*.tmp\n/root-only\nbuild/\n",
+		".sbignore":               "!keep.tmp\n",
+		"app.go":                  "",
+		"drop.tmp":                "",
+		"keep.tmp":                "",
+		"root-only":               "",
+		"build/out.bin":           "",
+		"src/root-only":           "",
+		"src/build":               "",
+		"src/.gitignore":          "*.gen\n/local\n",
+		"src/code.gen":            "",
+		"src/local":               "",
+		"src/nested/local":        "",
+		"src/nested/.sbignore":    "!code.gen\n",
+		"src/nested/code.gen":     "",
+		"other/code.gen":          "",
+		"other/local":             "",
+		".git/HEAD":               "",
+		"docs/.gitignore":         "!*.tmp\n",
+		"docs/notes.tmp":          "",
+		"docs/sub/.sbignore":      "notes.md\n",
+		"docs/sub/notes.md":       "",
+		"docs/notes.md":           "",
+		"vendor/.gitignore":       "*\n!.gitignore\n",
+		"vendor/lib/lib.go":       "",
+		"assets/images/logo.png":  "",
+		"assets/images/.sbignore": "*.png\n",
+	}
+	for name, body := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := packSource(dir, &buf); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			got = append(got, header.Name)
+		}
+	}
+	sort.Strings(got)
+
+	want := []string{
+		".gitignore",
+		".sbignore",
+		"app.go",
+		"assets/images/.sbignore",
+		"docs/.gitignore",
+		"docs/notes.md",
+		"docs/notes.tmp",
+		"docs/sub/.sbignore",
+		"keep.tmp",
+		"other/code.gen",
+		"other/local",
+		"src/.gitignore",
+		"src/build",
+		"src/nested/.sbignore",
+		"src/nested/code.gen",
+		"src/nested/local",
+		"src/root-only",
+		"vendor/.gitignore",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("packed files:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -5,81 +5,347 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var deployStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "List all Deployment Status",
-	Run:   deployStatus,
+	Long: `Lists the deployments of all apps. With --watch, the latest deployment of each
+app is polled and redrawn in place, highlighting apps whose deployment changed.`,
+	Run: deployStatus,
 }
 
+// Deployment statuses reported by the server.
+const (
+	deploymentPending   = "pending"
+	deploymentRunning   = "running"
+	deploymentSuccess   = "success"
+	deploymentFailed    = "failed"
+	deploymentCancelled = "cancelled"
+)
+
 type Deployment struct {
-	UUID   string `json:"uuid"`
-	Status string `json:"status"`
+	UUID        string `json:"uuid"`
+	Status      string `json:"status"`
+	Ref         string `json:"ref"`
+	CommitSHA   string `json:"commit_sha"`
+	Trigger     string `json:"trigger"`
+	TriggeredBy string `json:"triggered_by"`
+	CreatedAt   string `json:"created_at"`
+	StartedAt   string `json:"started_at"`
+	FinishedAt  string `json:"finished_at"`
+}
+
+// Revision describes what was built, preferring the short commit SHA.
+func (d Deployment) Revision() string {
+	switch {
+	case d.Ref != "" && d.CommitSHA != "":
+		return fmt.Sprintf("%s (%s)", d.Ref, shortSHA(d.CommitSHA))
+	case d.CommitSHA != "":
+		return shortSHA(d.CommitSHA)
+	default:
+		return d.Ref
+	}
+}
+
+// TriggerInfo combines what and who triggered the deployment.
+func (d Deployment) TriggerInfo() string {
+	switch {
+	case d.Trigger != "" && d.TriggeredBy != "":
+		return fmt.Sprintf("%s by %s", d.Trigger, d.TriggeredBy)
+	case d.TriggeredBy != "":
+		return d.TriggeredBy
+	default:
+		return d.Trigger
+	}
+}
+
+// Duration is the build and rollout time so far; running deployments count
+// up to now.
+func (d Deployment) Duration() time.Duration {
+	start, ok := parseTimestamp(d.StartedAt)
+	if !ok {
+		return 0
+	}
+	end, ok := parseTimestamp(d.FinishedAt)
+	if !ok {
+		end = time.Now()
+	}
+	return end.Sub(start).Round(time.Second)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func parseTimestamp(value string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// formatTimestamp renders an API timestamp in local time, or "-" if unset.
+func formatTimestamp(value string) string {
+	t, ok := parseTimestamp(value)
+	if !ok {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+var (
+	statusProject      string
+	statusWatch        bool
+	statusUntilSettled bool
+	statusInterval     time.Duration
+	statusWorkers      int
+)
+
+// fetchDeployments returns the deployments of an app, newest first.
+func fetchDeployments(appUUID string) ([]Deployment, error) {
+	sbUrl, token, _, err := getContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get context: %v", err)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/apps/%s/deployments/", sbUrl, appUUID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var deployments []Deployment
+	if err := json.NewDecoder(resp.Body).Decode(&deployments); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %v", err)
+	}
+
+	sort.SliceStable(deployments, func(i, j int) bool {
+		a, _ := parseTimestamp(deployments[i].CreatedAt)
+		b, _ := parseTimestamp(deployments[j].CreatedAt)
+		return a.After(b)
+	})
+	return deployments, nil
+}
+
+func fetchDeployment(deploymentUUID string) (Deployment, error) {
+	sbUrl, token, _, err := getContext()
+	if err != nil {
+		return Deployment{}, fmt.Errorf("failed to get context: %v", err)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/deployments/%s/", sbUrl, deploymentUUID), nil)
+	if err != nil {
+		return Deployment{}, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return Deployment{}, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Deployment{}, fmt.Errorf("deployment %s not found", deploymentUUID)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Deployment{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var deployment Deployment
+	if err := json.NewDecoder(resp.Body).Decode(&deployment); err != nil {
+		return Deployment{}, fmt.Errorf("failed to decode response body: %v", err)
+	}
+	return deployment, nil
+}
+
+// appDeployments is the result of polling the deployments of one app.
+type appDeployments struct {
+	App         App
+	Deployments []Deployment
+	Err         error
 }
 
 func deployStatus(cmd *cobra.Command, args []string) {
+	if statusInterval < time.Second {
+		fmt.Fprintln(os.Stderr, "Error: --interval must be at least 1s")
+		os.Exit(1)
+	}
+	if statusWorkers < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --concurrency must be at least 1")
+		os.Exit(1)
+	}
+
 	apps, err := fetchApps()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error fetching apps: %v\n", err)
 		return
 	}
 
-	sbUrl, token, _, err := getContext()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
-		return
+	if statusProject != "" {
+		var filtered []App
+		for _, app := range apps {
+			if app.Project.Name == statusProject || app.Project.UUID == statusProject {
+				filtered = append(filtered, app)
+			}
+		}
+		if len(filtered) == 0 {
+			fmt.Fprintf(os.Stderr, "No apps found in project %q.\n", statusProject)
+			os.Exit(1)
+		}
+		apps = filtered
 	}
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.SetStyle(table.StyleLight)
-	t.AppendHeader(table.Row{"App Name", "App UUID", "Status"})
 
-	for _, app := range apps {
-		fullUrl := fmt.Sprintf("%s/api/apps/%s/deployments/", sbUrl, app.UUID)
-		req, err := http.NewRequest("GET", fullUrl, nil)
-		if err != nil {
-			fmt.Println(err)
-			return
+	if !statusWatch {
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.SetStyle(table.StyleLight)
+		t.AppendHeader(table.Row{"App Name", "App UUID", "Status"})
+		for _, result := range pollDeployments(apps, statusWorkers) {
+			if result.Err != nil {
+				fmt.Fprintf(os.Stderr, "Error fetching deployments for app %s: %v\n", result.App.Name, result.Err)
+				continue
+			}
+			for _, deployment := range result.Deployments {
+				t.AppendRow([]interface{}{result.App.Name, result.App.UUID, deployment.Status})
+			}
 		}
+		t.Render()
+		return
+	}
 
-		req.Header.Add("Authorization", fmt.Sprintf("Token %s", token))
+	watchDeployments(apps)
+}
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			fmt.Println("Error sending request:", err)
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusOK {
-			///fmt.Println("Deployments Found.")
-		} else if resp.StatusCode == http.StatusUnauthorized {
-			fmt.Println("Authorization failed. Check your token.")
-		} else if resp.StatusCode == http.StatusNotFound {
-			fmt.Println("Deployments not found.")
-		} else {
-			fmt.Printf("Unexpected status code: %d\n", resp.StatusCode)
+// pollDeployments fetches the deployments of all apps using a bounded pool
+// of workers. Results keep the order of apps.
+func pollDeployments(apps []App, workers int) []appDeployments {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]appDeployments, len(apps))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				deployments, err := fetchDeployments(apps[i].UUID)
+				results[i] = appDeployments{App: apps[i], Deployments: deployments, Err: err}
+			}
+		}()
+	}
+	for i := range apps {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// watchDeployments redraws the latest deployment of every app until
+// interrupted or, with --until-settled, until none of them is running.
+func watchDeployments(apps []App) {
+	interactive := term.IsTerminal(int(os.Stdout.Fd()))
+	previous := map[string]Deployment{}
+
+	for {
+		results := pollDeployments(apps, statusWorkers)
+
+		t := table.NewWriter()
+		t.SetStyle(table.StyleLight)
+		t.AppendHeader(table.Row{"App Name", "Deployment", "Ref", "Status", "Duration"})
+		settled := true
+		for _, result := range results {
+			if result.Err != nil {
+				// The state of the app is unknown, so it cannot count as settled.
+				settled = false
+				t.AppendRow(table.Row{result.App.Name, "-", "-", text.FgRed.Sprintf("error: %v", result.Err), "-"})
+				continue
+			}
+			if len(result.Deployments) == 0 {
+				t.AppendRow(table.Row{result.App.Name, "-", "-", "no deployments", "-"})
+				continue
+			}
+
+			latest := result.Deployments[0]
+			if !deploymentFinished(latest.Status) {
+				settled = false
+			}
+
+			status := latest.Status
+			before, seen := previous[result.App.UUID]
+			changed := seen && (before.UUID != latest.UUID || before.Status != latest.Status)
+			if changed && before.UUID == latest.UUID {
+				status = fmt.Sprintf("%s -> %s", before.Status, latest.Status)
+			}
+			previous[result.App.UUID] = latest
+
+			row := table.Row{result.App.Name, latest.UUID, latest.Revision(), deploymentStatusColor(latest.Status).Sprint(status), latest.Duration()}
+			if changed {
+				row[0] = text.Colors{text.Bold, text.FgYellow}.Sprint(result.App.Name)
+			}
+			t.AppendRow(row)
 		}
 
-		var deployments []Deployment
-		if err := json.NewDecoder(resp.Body).Decode(&deployments); err != nil {
-			fmt.Println("Error decoding JSON:", err)
-			return
+		if interactive {
+			// Move to the top left and clear the screen before redrawing.
+			fmt.Print("\033[H\033[2J")
 		}
+		fmt.Printf("Deployments at %s (every %s, Ctrl-C to stop)\n", time.Now().Format("15:04:05"), statusInterval)
+		fmt.Println(t.Render())
 
-		for _, deployment := range deployments {
-			t.AppendRow([]interface{}{app.Name, app.UUID, deployment.Status})
+		if statusUntilSettled && settled {
+			return
 		}
+		time.Sleep(statusInterval)
 	}
+}
 
-	t.Render()
+func deploymentStatusColor(status string) text.Colors {
+	switch status {
+	case deploymentSuccess:
+		return text.Colors{text.FgGreen}
+	case deploymentFailed:
+		return text.Colors{text.FgRed}
+	case deploymentCancelled:
+		return text.Colors{text.Faint}
+	default:
+		return text.Colors{text.FgCyan}
+	}
 }
 
 func init() {
 	deployCmd.AddCommand(deployStatusCmd)
+	deployStatusCmd.Flags().StringVar(&statusProject, "project", "", "Only show apps of this project (name or UUID)")
+	deployStatusCmd.Flags().BoolVarP(&statusWatch, "watch", "w", false, "Keep refreshing the latest deployment of each app")
+	deployStatusCmd.Flags().BoolVar(&statusUntilSettled, "until-settled", false, "With --watch, exit once no deployment is pending or running and every app could be fetched")
+	deployStatusCmd.Flags().DurationVar(&statusInterval, "interval", 5*time.Second, "Refresh interval for --watch, at least 1s")
+	deployStatusCmd.Flags().IntVar(&statusWorkers, "concurrency", 8, "Number of apps polled in parallel")
 }
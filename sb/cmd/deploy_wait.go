@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	errBuildFailed   = errors.New("build failed")
+	errRolloutFailed = errors.New("rollout failed")
+)
+
+// Container waiting reasons that will not resolve without a new deployment.
+var fatalWaitingReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+}
+
+// deploymentFinished reports whether status is final.
+func deploymentFinished(status string) bool {
+	switch status {
+	case deploymentSuccess, deploymentFailed, deploymentCancelled:
+		return true
+	}
+	return false
+}
+
+// podFailure returns why pod has failed or is stuck, or nil if it is healthy
+// or still progressing.
+func podFailure(pod *corev1.Pod) error {
+	if pod.Status.Phase == corev1.PodFailed {
+		reason := pod.Status.Reason
+		if reason == "" {
+			reason = "PodFailed"
+		}
+		return fmt.Errorf("pod %s failed (%s)", pod.Name, reason)
+	}
+	statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if waiting := status.State.Waiting; waiting != nil && fatalWaitingReasons[waiting.Reason] {
+			return fmt.Errorf("container %s in pod %s is in %s: %s", status.Name, pod.Name, waiting.Reason, waiting.Message)
+		}
+	}
+	return nil
+}
+
+// appClientset connects to the cluster of an app using its shell-info
+// kubeconfig and returns the clientset and the app's namespace.
+func appClientset(appUUID string) (*kubernetes.Clientset, string, error) {
+	cluster, err := connectApp(appUUID)
+	if err != nil {
+		return nil, "", err
+	}
+	return cluster.clientset, cluster.namespace, nil
+}
+
+// checkRollout looks for pods of deployment that are stuck pulling their
+// image or crash looping. Pods created before the deployment started belong
+// to the previous release and are ignored, so a deployment can fix an app
+// that is crash looping.
+func checkRollout(ctx context.Context, clientset *kubernetes.Clientset, namespace, appUUID string, deployment Deployment) error {
+	startedAt, ok := parseTimestamp(deployment.StartedAt)
+	if !ok {
+		return nil
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("appUuid=%s", appUUID),
+	})
+	if err != nil {
+		return nil
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.CreationTimestamp.Time.Before(startedAt) {
+			continue
+		}
+		// Finished pods are left over from earlier deployments.
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if err := podFailure(pod); err != nil {
+			return fmt.Errorf("%w: %v", errRolloutFailed, err)
+		}
+	}
+	return nil
+}
+
+// buildFinished reports whether the build pod of a deployment has completed
+// or, once the build is done, been removed.
+func buildFinished(ctx context.Context, sbUrl, token, deploymentUUID string) bool {
+	podInfo, ready, err := fetchPodInfo(ctx, sbUrl, token, deploymentUUID)
+	if err != nil || !ready {
+		return false
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(podInfo.KubeConfig))
+	if err != nil {
+		return false
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return false
+	}
+	pod, err := clientset.CoreV1().Pods(podInfo.Namespace).Get(ctx, podInfo.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true
+	}
+	return err == nil && pod.Status.Phase == corev1.PodSucceeded
+}
+
+// waitForDeployment polls the deployments API until the deployment finishes,
+// failing early when the rollout gets stuck. Pods are only checked once the
+// build is done, as there is nothing to roll out before.
+func waitForDeployment(ctx context.Context, appUUID, deploymentUUID string) (Deployment, error) {
+	// Kubernetes access only improves failure detection, so it is optional.
+	clientset, namespace, kubeErr := appClientset(appUUID)
+	sbUrl, token, _, err := getContext()
+	if err != nil {
+		return Deployment{}, err
+	}
+	built := false
+
+	for {
+		deployment, err := fetchDeployment(deploymentUUID)
+		if err != nil {
+			return Deployment{}, err
+		}
+		if deploymentFinished(deployment.Status) {
+			return deployment, nil
+		}
+		if kubeErr == nil && !built {
+			built = buildFinished(ctx, sbUrl, token, deploymentUUID)
+		}
+		if kubeErr == nil && built {
+			if err := checkRollout(ctx, clientset, namespace, appUUID, deployment); err != nil {
+				return deployment, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return deployment, ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// finishDeployment follows and/or waits for a new deployment as requested,
+// notifies the given targets once it ends and exits non-zero if it did not
+// succeed. Without follow or wait it returns right away and sends nothing.
+func finishDeployment(sbUrl, token string, app App, deploymentUUID string, follow, wait bool, timeout time.Duration, targets []notifyTarget) {
+	if !follow && !wait {
+		return
+	}
+	// Notifications need the final status, which following alone does not
+	// report.
+	wait = wait || len(targets) > 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	deployment := Deployment{UUID: deploymentUUID}
+	var failure error
+	if follow {
+		err := followDeployment(ctx, sbUrl, token, deploymentUUID)
+		switch {
+		case errors.Is(err, errBuildFailed), errors.Is(err, context.DeadlineExceeded):
+			failure = err
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	if failure == nil && wait {
+		deployment, failure = waitForDeployment(ctx, app.UUID, deploymentUUID)
+	}
+
+	switch {
+	case errors.Is(failure, context.DeadlineExceeded):
+		fmt.Fprintf(os.Stderr, "Timed out after %s waiting for deployment %s (status %s).\n", timeout, deploymentUUID, deployment.Status)
+	case failure != nil:
+		fmt.Fprintf(os.Stderr, "Deployment %s failed: %v\n", deploymentUUID, failure)
+	case wait:
+		fmt.Printf("Deployment %s finished with status %s after %s.\n", deployment.UUID, deployment.Status, deployment.Duration())
+	}
+
+	if len(targets) > 0 {
+		event := deploymentEvent{
+			App:        app.Name,
+			AppUUID:    app.UUID,
+			Deployment: deploymentUUID,
+			Ref:        deployment.Revision(),
+			Status:     deployment.Status,
+			Duration:   deployment.Duration().Seconds(),
+			URL:        fmt.Sprintf("%s/apps/%s/deployments/%s/", sbUrl, app.UUID, deploymentUUID),
+		}
+		if event.Ref == "" {
+			event.Ref = app.Ref
+		}
+		if failure != nil {
+			event.Status = deploymentFailed
+			if errors.Is(failure, context.DeadlineExceeded) {
+				event.Status = "timeout"
+			}
+			event.Message = failure.Error()
+		}
+		if err := sendNotifications(targets, event); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if failure != nil || (wait && deployment.Status != deploymentSuccess) {
+		os.Exit(1)
+	}
+}
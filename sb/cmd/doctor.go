@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/shapeblock/sb-cli/sb/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+type doctorReport struct {
+	ClientVersion string        `json:"client_version"`
+	OS            string        `json:"os"`
+	Arch          string        `json:"arch"`
+	ConfigFile    string        `json:"config_file"`
+	Context       string        `json:"context"`
+	Endpoint      string        `json:"endpoint"`
+	Checks        []doctorCheck `json:"checks"`
+}
+
+func (r *doctorReport) add(name, status, detail, hint string) {
+	r.Checks = append(r.Checks, doctorCheck{Name: name, Status: status, Detail: detail, Hint: hint})
+}
+
+func (r *doctorReport) failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == checkFail {
+			return true
+		}
+	}
+	return false
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose configuration and connectivity problems",
+	Long: `Checks the config file, the current context's server, the login token, server
+compatibility, the GitHub integration and, with --app, Kubernetes access for an app.`,
+	Run: doctor,
+}
+
+var (
+	doctorApp  string
+	doctorJSON bool
+)
+
+func doctor(cmd *cobra.Command, args []string) {
+	report := &doctorReport{
+		ClientVersion: config.GetVersion(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		ConfigFile:    viper.ConfigFileUsed(),
+	}
+
+	contextInfo, ok := checkConfig(report)
+	if ok {
+		caps, reachable := checkEndpoint(report, contextInfo)
+		if reachable {
+			tokenValid := checkToken(report, contextInfo)
+			checkServer(report, contextInfo, caps)
+			checkGithub(report, contextInfo)
+			if tokenValid {
+				checkKubernetes(report)
+			} else {
+				report.add("kubernetes", checkSkip, "token is not valid", "")
+			}
+		}
+	}
+
+	if doctorJSON {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to marshal report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+	} else {
+		printDoctorReport(report)
+	}
+
+	if report.failed() {
+		os.Exit(1)
+	}
+}
+
+// checkConfig validates the config file and returns the current context.
+func checkConfig(report *doctorReport) (ContextInfo, bool) {
+	configFile := report.ConfigFile
+	info, err := os.Stat(configFile)
+	if err != nil {
+		report.add("config file", checkFail, err.Error(), "run 'sb-cli login' to create it")
+		return ContextInfo{}, false
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		report.add("config permissions", checkWarn,
+			fmt.Sprintf("%s is accessible by other users (%s)", configFile, info.Mode().Perm()),
+			fmt.Sprintf("chmod 600 %s", configFile))
+	} else {
+		report.add("config permissions", checkPass, info.Mode().Perm().String(), "")
+	}
+
+	cfg, err := readConfig(configFile)
+	if err != nil {
+		report.add("config schema", checkFail, err.Error(), "fix or remove the file and log in again")
+		return ContextInfo{}, false
+	}
+
+	for name, contextInfo := range cfg.Contexts {
+		if contextInfo.Endpoint == "" || contextInfo.Token == "" || contextInfo.Server == "" {
+			report.add("config schema", checkWarn,
+				fmt.Sprintf("context %q is missing its endpoint, token or server", name),
+				"log in to the server again")
+		}
+	}
+
+	report.Context = cfg.CurrentContext
+	contextInfo, exists := cfg.Contexts[cfg.CurrentContext]
+	if cfg.CurrentContext == "" || !exists {
+		report.add("config schema", checkFail, "no current context is set", "run 'sb-cli login' or 'sb-cli switch'")
+		return ContextInfo{}, false
+	}
+	report.Endpoint = contextInfo.Endpoint
+	report.add("config schema", checkPass, fmt.Sprintf("%d context(s), current %q", len(cfg.Contexts), cfg.CurrentContext), "")
+	return contextInfo, true
+}
+
+// checkEndpoint verifies the server can be reached over TLS and discovers
+// its capabilities.
+func checkEndpoint(report *doctorReport, contextInfo ContextInfo) (Capabilities, bool) {
+	caps, err := discoverCapabilities(contextInfo.Endpoint, contextInfo.Token)
+	if err != nil {
+		var netErr *NetworkError
+		if errors.As(err, &netErr) {
+			name := "endpoint reachable"
+			if netErr.Kind == NetworkErrorTLS {
+				name = "endpoint TLS"
+			}
+			report.add(name, checkFail, fmt.Sprintf("%v", netErr.Err), netErr.Hint())
+		} else {
+			report.add("endpoint reachable", checkFail, err.Error(), "")
+		}
+		return Capabilities{}, false
+	}
+
+	report.add("endpoint reachable", checkPass, contextInfo.Endpoint, "")
+	switch {
+	case strings.HasPrefix(contextInfo.Endpoint, "http://"):
+		report.add("endpoint TLS", checkWarn, "the endpoint does not use HTTPS", "log in with an https:// server address")
+	case contextInfo.InsecureSkipVerify:
+		report.add("endpoint TLS", checkWarn, "certificate verification is disabled", "set ca_file on the context instead of insecure_skip_verify")
+	default:
+		report.add("endpoint TLS", checkPass, "certificate verified", "")
+	}
+	return caps, true
+}
+
+func checkToken(report *doctorReport, contextInfo ContextInfo) bool {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/apps/", contextInfo.Endpoint), nil)
+	if err != nil {
+		report.add("token", checkFail, err.Error(), "")
+		return false
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", contextInfo.Token))
+
+	resp, err := doRequest(req)
+	if err != nil {
+		report.add("token", checkFail, err.Error(), "")
+		return false
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		report.add("token", checkPass, "token accepted", "")
+		return true
+	case http.StatusUnauthorized, http.StatusForbidden:
+		report.add("token", checkFail, fmt.Sprintf("server rejected the token (%s)", resp.Status), "run 'sb-cli login'")
+	default:
+		report.add("token", checkFail, fmt.Sprintf("unexpected status %s", resp.Status), "")
+	}
+	return false
+}
+
+func checkServer(report *doctorReport, contextInfo ContextInfo, caps Capabilities) {
+	if contextInfo.Server != caps.Server {
+		report.add("server type", checkWarn,
+			fmt.Sprintf("context says %q but the server reports %q", contextInfo.Server, caps.Server),
+			"run 'sb-cli login' to refresh the context")
+	} else {
+		report.add("server type", checkPass, caps.Server, "")
+	}
+
+	compatible, reason := checkCompatibility(caps)
+	switch {
+	case !compatible:
+		report.add("server version", checkFail, reason, "upgrade sb-cli or the ShapeBlock server")
+	case caps.Version == "":
+		report.add("server version", checkWarn, reason, "")
+	default:
+		report.add("server version", checkPass, reason, "")
+	}
+}
+
+func checkGithub(report *doctorReport, contextInfo ContextInfo) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/github-client/", contextInfo.Endpoint), nil)
+	if err != nil {
+		report.add("github", checkFail, err.Error(), "")
+		return
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", contextInfo.Token))
+
+	resp, err := doRequest(req)
+	if err != nil {
+		report.add("github", checkFail, err.Error(), "")
+		return
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		report.add("github", checkPass, "GitHub integration is configured", "")
+	case http.StatusNotFound:
+		report.add("github", checkWarn, "GitHub integration is not configured on the server",
+			"set GITHUB_CLIENT_KEY and GITHUB_CLIENT_SECRET on the server and re-deploy it")
+	default:
+		report.add("github", checkWarn, fmt.Sprintf("unexpected status %s", resp.Status), "")
+	}
+}
+
+// checkKubernetes uses the shell-info kubeconfig of --app to look up the
+// app's pod.
+func checkKubernetes(report *doctorReport) {
+	if doctorApp == "" {
+		report.add("kubernetes", checkSkip, "no app given", "pass --app NAME to check Kubernetes access")
+		return
+	}
+
+	app, err := resolveApp(doctorApp)
+	if err != nil {
+		report.add("kubernetes", checkFail, err.Error(), "")
+		return
+	}
+
+	shellInfo, err := fetchShellInfo(app.UUID)
+	if err != nil {
+		report.add("kubernetes", checkFail, fmt.Sprintf("shell-info: %v", err), "check that the app has been deployed")
+		return
+	}
+
+	kubeConfig, err := base64.StdEncoding.DecodeString(shellInfo.KubeConfig)
+	if err != nil {
+		report.add("kubernetes", checkFail, fmt.Sprintf("invalid kubeconfig: %v", err), "")
+		return
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+	if err != nil {
+		report.add("kubernetes", checkFail, fmt.Sprintf("invalid kubeconfig: %v", err), "")
+		return
+	}
+	restConfig.Timeout = 10 * time.Second
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		report.add("kubernetes", checkFail, err.Error(), "")
+		return
+	}
+
+	pod, err := clientset.CoreV1().Pods(shellInfo.Namespace).Get(context.Background(), shellInfo.Name, metav1.GetOptions{})
+	if err != nil {
+		report.add("kubernetes", checkFail, err.Error(), "check that the cluster API server is reachable from this machine")
+		return
+	}
+	report.add("kubernetes", checkPass, fmt.Sprintf("pod %s is %s", pod.Name, pod.Status.Phase), "")
+}
+
+func printDoctorReport(report *doctorReport) {
+	fmt.Printf("sb-cli %s (%s/%s)\n", report.ClientVersion, report.OS, report.Arch)
+	if report.Context != "" {
+		fmt.Printf("Context: %s\n", report.Context)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetStyle(table.StyleLight)
+	t.AppendHeader(table.Row{"Status", "Check", "Detail"})
+	for _, check := range report.Checks {
+		detail := check.Detail
+		if check.Hint != "" {
+			detail = fmt.Sprintf("%s\nhint: %s", detail, check.Hint)
+		}
+		t.AppendRow(table.Row{statusColor(check.Status).Sprint(check.Status), check.Name, detail})
+	}
+	t.Render()
+}
+
+func statusColor(status string) text.Colors {
+	switch status {
+	case checkPass:
+		return text.Colors{text.FgGreen}
+	case checkWarn:
+		return text.Colors{text.FgYellow}
+	case checkFail:
+		return text.Colors{text.FgRed}
+	default:
+		return text.Colors{text.Faint}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().StringVar(&doctorApp, "app", "", "App name or UUID to check Kubernetes access with")
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Print the report as JSON")
+}
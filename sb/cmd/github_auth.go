@@ -41,7 +41,7 @@ func fetchGithubClientCredentials() (GithubClient, error) {
 	sbUrl, token, _, err := getContext()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
-		return GithubClient{}, nil
+		return GithubClient{}, err
 	}
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/github-client/", sbUrl), nil)
@@ -49,18 +49,16 @@ func fetchGithubClientCredentials() (GithubClient, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-
-	if resp.StatusCode == http.StatusNotFound {
-		return GithubClient{}, fmt.Errorf("this installation cannnot be integrated with Github. Please add a GITHUB_CLIENT_KEY and GITHUB_CLIENT_SECRET and re-deploy the application")
-	}
-
+	resp, err := doRequest(req)
 	if err != nil {
 		return GithubClient{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return GithubClient{}, fmt.Errorf("this installation cannnot be integrated with Github. Please add a GITHUB_CLIENT_KEY and GITHUB_CLIENT_SECRET and re-deploy the application")
+	}
+
 	var githubClient GithubClient
 	if err := json.NewDecoder(resp.Body).Decode(&githubClient); err != nil {
 		return GithubClient{}, err
@@ -73,7 +71,7 @@ func sendGithubTokenToBackend(githubToken string) error {
 	sbUrl, token, _, err := getContext()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
-		return nil
+		return err
 	}
 
 	data := map[string]string{
@@ -91,8 +89,7 @@ func sendGithubTokenToBackend(githubToken string) error {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return err
 	}
@@ -117,6 +114,8 @@ var githubAuthCmd = &cobra.Command{
 	Aliases: []string{"github"},
 	Short:   "Authenticate with Github",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		requireFeature(FeatureGithub, "This installation cannot be integrated with Github. Please add a GITHUB_CLIENT_KEY and GITHUB_CLIENT_SECRET and re-deploy the application.")(cmd, args)
+
 		githubClient, err := fetchGithubClientCredentials()
 
 		if err != nil {
@@ -20,13 +20,47 @@ type ContextInfo struct {
 	Server    string `json:"server"`
 	Token     string `json:"token"`
 	Timestamp string `json:"timestamp"`
+
+	// TLS settings for self-hosted servers behind a private CA.
+	CAFile             string `json:"ca_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+	ClientCert         string `json:"client_cert,omitempty"`
+	ClientKey          string `json:"client_key,omitempty"`
+
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	// When stale capabilities were last refreshed, successfully or not,
+	// before an unrelated command.
+	CapabilitiesTriedAt string `json:"capabilities_tried_at,omitempty"`
+	// When the out-of-range server version warning was last shown.
+	CompatWarnedAt string `json:"compat_warned_at,omitempty"`
+	// Default deployment notification targets, see notify.go.
+	Notify []string `json:"notify,omitempty"`
+}
+
+// hasTLSSettings reports whether any TLS option is set on the context.
+func (c ContextInfo) hasTLSSettings() bool {
+	return c.CAFile != "" || c.InsecureSkipVerify || c.ClientCert != "" || c.ClientKey != ""
+}
+
+func (c *ContextInfo) setTLSSettings(from ContextInfo) {
+	c.CAFile = from.CAFile
+	c.InsecureSkipVerify = from.InsecureSkipVerify
+	c.ClientCert = from.ClientCert
+	c.ClientKey = from.ClientKey
 }
 
 type Config struct {
 	Contexts       map[string]ContextInfo `json:"contexts"`
 	CurrentContext string                 `json:"current-context"`
+
+	// Self-update settings, see update.go.
+	UpdateChannel   string `json:"update-channel,omitempty"`
+	UpdateFeed      string `json:"update-feed,omitempty"`
+	UpdatePublicKey string `json:"update-public-key,omitempty"`
 }
 
+var loginTLS ContextInfo
+
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Log in to the Shapeblock server",
@@ -58,6 +92,10 @@ func performLogin() error {
 		sbUrl = fmt.Sprintf("https://%s", url)
 	}
 
+	if loginTLS.hasTLSSettings() {
+		setEndpointTLS(sbUrl, loginTLS)
+	}
+
 	prompt = promptui.Prompt{
 		Label: "Email (enter your username if you're using the open source version)",
 	}
@@ -79,21 +117,11 @@ func performLogin() error {
 	}
 
 	// Determine the server type (OSS or SaaS)
-	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/auth/registration/", sbUrl), nil)
-	req.Header.Add("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	caps, err := discoverCapabilities(sbUrl, "")
 	if err != nil {
-		fmt.Printf("Server check failed: %v\n", err)
-
-	}
-	defer resp.Body.Close()
-
-	serverType := "oss"
-	if resp.StatusCode != http.StatusNotFound {
-		serverType = "saas"
+		return fmt.Errorf("server check failed: %w", err)
 	}
+	serverType := caps.Server
 
 	token, err := SbLogin(username, password, sbUrl, serverType)
 	if err != nil {
@@ -106,6 +134,10 @@ func performLogin() error {
 	contextInfo.Server = serverType
 	contextInfo.Endpoint = sbUrl
 	contextInfo.Timestamp = time.Now().Format(time.RFC3339)
+	contextInfo.Capabilities = &caps
+	if loginTLS.hasTLSSettings() {
+		contextInfo.setTLSSettings(loginTLS)
+	}
 
 	// Load the existing configuration manually
 	configFile := viper.ConfigFileUsed()
@@ -138,6 +170,10 @@ func performLogin() error {
 			existingContext.Token = contextInfo.Token
 		}
 		existingContext.Timestamp = contextInfo.Timestamp
+		existingContext.Capabilities = contextInfo.Capabilities
+		if loginTLS.hasTLSSettings() {
+			existingContext.setTLSSettings(loginTLS)
+		}
 		cfg.Contexts[sbUrl] = existingContext
 	} else {
 		cfg.Contexts[sbUrl] = contextInfo
@@ -185,7 +221,13 @@ func SbLogin(username, password, sbUrl string, serverType string) (string, error
 	}
 
 	// Perform HTTP POST request to token-login endpoint
-	resp, err := http.Post(tokenLoginUrl, "application/json", bytes.NewBuffer(body))
+	req, err := http.NewRequest("POST", tokenLoginUrl, bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := doRequest(req)
 	if err != nil {
 		return "", err
 	}
@@ -215,4 +257,8 @@ func SbLogin(username, password, sbUrl string, serverType string) (string, error
 
 func init() {
 	rootCmd.AddCommand(loginCmd)
+	loginCmd.Flags().StringVar(&loginTLS.CAFile, "ca-file", "", "PEM bundle of CA certificates to trust for this server")
+	loginCmd.Flags().BoolVar(&loginTLS.InsecureSkipVerify, "insecure-skip-verify", false, "Skip TLS certificate verification (not recommended)")
+	loginCmd.Flags().StringVar(&loginTLS.ClientCert, "client-cert", "", "PEM client certificate for mutual TLS")
+	loginCmd.Flags().StringVar(&loginTLS.ClientKey, "client-key", "", "PEM private key for the client certificate")
 }
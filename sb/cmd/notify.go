@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	notifySlack   = "slack"
+	notifyWebhook = "webhook"
+	notifyDesktop = "desktop"
+	// notifyNone turns off the context's default notifications.
+	notifyNone = "none"
+)
+
+// notifyTarget is a parsed --notify value such as "slack=URL" or "desktop".
+type notifyTarget struct {
+	Kind string
+	URL  string
+}
+
+// deploymentEvent is the payload sent to webhooks when a deployment ends.
+type deploymentEvent struct {
+	App        string  `json:"app"`
+	AppUUID    string  `json:"app_uuid"`
+	Deployment string  `json:"deployment"`
+	Ref        string  `json:"ref"`
+	Status     string  `json:"status"`
+	Duration   float64 `json:"duration_seconds"`
+	URL        string  `json:"url"`
+	Message    string  `json:"message,omitempty"`
+}
+
+func (e deploymentEvent) summary() string {
+	summary := fmt.Sprintf("Deployment of %s (%s) finished with status %s after %s", e.App, e.Ref, e.Status, time.Duration(e.Duration*float64(time.Second)))
+	if e.Message != "" {
+		summary += ": " + e.Message
+	}
+	return summary
+}
+
+func parseNotifyTargets(values []string) ([]notifyTarget, error) {
+	var targets []notifyTarget
+	for _, value := range values {
+		kind, url, _ := strings.Cut(value, "=")
+		switch kind {
+		case notifySlack, notifyWebhook:
+			if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+				return nil, fmt.Errorf("--notify %s needs a URL, e.g. %s=https://...", kind, kind)
+			}
+		case notifyDesktop:
+			if url != "" {
+				return nil, fmt.Errorf("--notify desktop does not take a value")
+			}
+		case notifyNone:
+			return nil, fmt.Errorf("--notify none cannot be combined with other targets")
+		default:
+			return nil, fmt.Errorf("unknown notification target %q, use slack=URL, webhook=URL, desktop or none", value)
+		}
+		targets = append(targets, notifyTarget{Kind: kind, URL: url})
+	}
+	return targets, nil
+}
+
+// notifyTargets returns the targets given with --notify, falling back to the
+// notify list of the current context. "--notify none" disables notifications.
+func notifyTargets(flagValues []string) ([]notifyTarget, error) {
+	if len(flagValues) == 1 && flagValues[0] == notifyNone {
+		return nil, nil
+	}
+	if len(flagValues) > 0 {
+		return parseNotifyTargets(flagValues)
+	}
+	cfg, err := readConfig(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, nil
+	}
+	return parseNotifyTargets(cfg.Contexts[cfg.CurrentContext].Notify)
+}
+
+// notifyRequested reports whether --notify named targets explicitly. Those
+// imply waiting for the deployment, while the context's defaults only apply
+// when waiting or following anyway.
+func notifyRequested(flagValues []string) bool {
+	return len(flagValues) > 0 && !(len(flagValues) == 1 && flagValues[0] == notifyNone)
+}
+
+// sendNotifications delivers event to every target. Failures are returned
+// together so one broken target does not hide the others.
+func sendNotifications(targets []notifyTarget, event deploymentEvent) error {
+	var failures []string
+	for _, target := range targets {
+		var err error
+		switch target.Kind {
+		case notifySlack:
+			err = postJSON(target.URL, slackMessage(event))
+		case notifyWebhook:
+			err = postJSON(target.URL, event)
+		case notifyDesktop:
+			err = desktopNotification("sb-cli", event.summary())
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", target.Kind, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to send notifications: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func slackMessage(event deploymentEvent) map[string]interface{} {
+	emoji := ":white_check_mark:"
+	if event.Status != deploymentSuccess {
+		emoji = ":x:"
+	}
+	fields := []map[string]string{
+		{"type": "mrkdwn", "text": fmt.Sprintf("*App*\n%s", event.App)},
+		{"type": "mrkdwn", "text": fmt.Sprintf("*Ref*\n%s", event.Ref)},
+		{"type": "mrkdwn", "text": fmt.Sprintf("*Status*\n%s", event.Status)},
+		{"type": "mrkdwn", "text": fmt.Sprintf("*Duration*\n%s", time.Duration(event.Duration*float64(time.Second)))},
+	}
+	text := fmt.Sprintf("%s Deployment of *%s* %s", emoji, event.App, event.Status)
+	if event.Message != "" {
+		text += ": " + event.Message
+	}
+	return map[string]interface{}{
+		"text": event.summary(),
+		"blocks": []map[string]interface{}{
+			{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": text}},
+			{"type": "section", "fields": fields},
+			{"type": "context", "elements": []map[string]string{
+				{"type": "mrkdwn", "text": fmt.Sprintf("<%s|Deployment %s>", event.URL, event.Deployment)},
+			}},
+		},
+	}
+}
+
+func postJSON(url string, payload interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+func desktopNotification(title, message string) error {
+	switch runtime.GOOS {
+	case "linux":
+		return exec.Command("notify-send", title, message).Run()
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, title)
+		return exec.Command("osascript", "-e", script).Run()
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+}
@@ -55,7 +55,6 @@ func projectDelete(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
 		return
 	}
-	client := &http.Client{}
 	projects, err := fetchProjects()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error fetching projects: %v\n", err)
@@ -84,9 +83,10 @@ func projectDelete(cmd *cobra.Command, args []string) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
 
 	defer resp.Body.Close()
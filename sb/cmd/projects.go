@@ -28,10 +28,9 @@ func fetchProjects() ([]Project, error) {
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
 	//fmt.Println("Request URL:", req.URL.String())
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		return nil, fmt.Errorf("error making request: %w", err)
 	}
 	defer resp.Body.Close()
 
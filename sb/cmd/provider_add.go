@@ -34,28 +34,6 @@ func createProvider(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	// Check clusters
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/clusters/", sbUrl), nil)
-	if err != nil {
-		fmt.Printf("Error creating GET request: %v\n", err)
-		return
-	}
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("Error making GET request: %v\n", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		fmt.Println("This instance cannot manage providers.")
-		return
-	}
-
 	// Prompt for cloud provider details
 	name := prompt("Enter the cloud provider name", true)
 	cloudPrompt := promptui.Select{
@@ -104,39 +82,46 @@ func createProvider(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if err := postProvider(sbUrl, token, provider); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("New provider created successfully.")
+}
+
+// postProvider asks the server to create provider.
+func postProvider(sbUrl, token string, provider CloudProvider) error {
 	// Marshal provider data to JSON
 	jsonData, err := json.Marshal(provider)
 	if err != nil {
-		fmt.Printf("Error marshaling JSON: %v\n", err)
-		return
+		return fmt.Errorf("error marshaling JSON: %w", err)
 	}
 
 	// Create and send HTTP request to create provider
-	req, err = http.NewRequest("POST", fmt.Sprintf("%s/api/providers/", sbUrl), bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/providers/", sbUrl), bytes.NewBuffer(jsonData))
 	if err != nil {
-		fmt.Printf("Error creating POST request: %v\n", err)
-		return
+		return fmt.Errorf("error creating POST request: %w", err)
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	resp, err = client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
-		fmt.Printf("Error making POST request: %v\n", err)
-		return
+		return err
 	}
 	defer resp.Body.Close()
 
 	// Check response status for provider creation
-	if resp.StatusCode == http.StatusCreated {
-		fmt.Println("New provider created successfully.")
-	} else if resp.StatusCode == http.StatusUnauthorized {
-		fmt.Println("Authorization failed. Check your token.")
-	} else if resp.StatusCode == http.StatusBadRequest {
-		fmt.Println("Unable to create provider, bad request.")
-	} else if resp.StatusCode == http.StatusInternalServerError {
-		fmt.Println("Unable to create provider, internal server error.")
-	} else {
-		fmt.Printf("Unexpected status code: %d\n", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+	case http.StatusUnauthorized:
+		return fmt.Errorf("Authorization failed. Check your token.")
+	case http.StatusBadRequest:
+		return fmt.Errorf("Unable to create provider, bad request.")
+	case http.StatusInternalServerError:
+		return fmt.Errorf("Unable to create provider, internal server error.")
+	default:
+		return fmt.Errorf("Unexpected status code: %d", resp.StatusCode)
 	}
 }
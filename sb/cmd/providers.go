@@ -26,7 +26,7 @@ func fetchProviders() ([]Provider, error) {
 	sbUrl, token, _, err := getContext()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
-		return []Provider{}, nil
+		return []Provider{}, err
 	}
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/providers/", sbUrl), nil)
@@ -34,18 +34,16 @@ func fetchProviders() ([]Provider, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("this instance cannot manage providers")
-	}
-
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("this instance cannot manage providers")
+	}
+
 	var providers []Provider
 	if err := json.NewDecoder(resp.Body).Decode(&providers); err != nil {
 		return nil, err
@@ -55,9 +53,10 @@ func fetchProviders() ([]Provider, error) {
 }
 
 var providersCmd = &cobra.Command{
-	Use:     "providers",
-	Aliases: []string{"provider"},
-	Short:   "Do things with cloud providers",
+	Use:              "providers",
+	Aliases:          []string{"provider"},
+	Short:            "Do things with cloud providers",
+	PersistentPreRun: requireFeature(FeatureProviders, "This instance cannot manage providers."),
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
 	},
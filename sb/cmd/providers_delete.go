@@ -33,7 +33,6 @@ var providerDeleteCmd = &cobra.Command{
 			fmt.Fprintf(os.Stderr, "Error getting context: %v\n", err)
 			return
 		}
-		client := &http.Client{}
 
 		providers, err := fetchProviders()
 		if err != nil {
@@ -63,9 +62,10 @@ var providerDeleteCmd = &cobra.Command{
 		req.Header.Add("Content-Type", "application/json")
 		req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-		resp, err := client.Do(req)
+		resp, err := doRequest(req)
 		if err != nil {
 			fmt.Println(err)
+			return
 		}
 
 		defer resp.Body.Close()
@@ -10,6 +10,7 @@ import (
 
 	//"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/manifoldco/promptui"
@@ -42,19 +43,14 @@ var registerCmd = &cobra.Command{
 			sbUrl = fmt.Sprintf("https://%s", url)
 		}
 
-		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/auth/registration/", sbUrl), nil)
-		req.Header.Add("Content-Type", "application/json")
-
-		client := &http.Client{}
-		resp, err := client.Do(req)
-
-		if resp.StatusCode == http.StatusNotFound {
-			fmt.Println("This instance cannot manage registrations.")
+		caps, err := discoverCapabilities(sbUrl, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Server check failed: %v\n", err)
 			return
 		}
 
-		if err != nil {
-			fmt.Printf("Prompt failed %v\n", err)
+		if !caps.Has(FeatureRegistration) {
+			fmt.Println("This instance cannot manage registrations.")
 			return
 		}
 
@@ -124,7 +120,6 @@ func SbRegister(sbUrl string, email string, password1 string, password2 string)
 		return "", err
 	}
 
-	client := &http.Client{}
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
 
 	if err != nil {
@@ -132,7 +127,7 @@ func SbRegister(sbUrl string, email string, password1 string, password2 string)
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	res, err := client.Do(req)
+	res, err := doRequest(req)
 	if err != nil {
 		fmt.Println(err)
 		return "", err
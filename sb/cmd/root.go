@@ -37,6 +37,10 @@ Your infrastructure, your code, your rules, our automation. `,
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	hideUnsupportedCommands()
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil && !skipCompatWarning(cmd) {
+		warnIfIncompatible()
+	}
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
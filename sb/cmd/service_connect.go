@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/portforward"
+)
+
+var svcConnectCmd = &cobra.Command{
+	Use:   "connect [-- CLIENT ARGS...]",
+	Short: "Open a database client for a service",
+	Long: `Opens the client of a postgres, mysql, mongodb or redis service (psql, mysql,
+mongosh or redis-cli) with the service's credentials filled in:
+
+  sb-cli services connect --service mydb
+  sb-cli services connect --service mydb -- -c "select count(*) from users"
+
+When the client is installed locally, it connects through a port-forward.
+Otherwise, or with --in-cluster, the client in the service's pod is used.
+The password is handed to the client through its environment and never
+appears on a command line.`,
+	Run: svcConnect,
+}
+
+var (
+	connectService   string
+	connectInCluster bool
+)
+
+// serviceClient describes the command line client of a service type.
+type serviceClient struct {
+	Binary string
+	User   string
+	// Keys of the service's secret that may hold the password, in order of
+	// preference.
+	PasswordKeys []string
+	// Environment variable the client reads the password from.
+	PasswordEnv string
+}
+
+var serviceClients = map[string]serviceClient{
+	"postgres": {Binary: "psql", User: "postgres", PasswordKeys: []string{"postgres-password", "password"}, PasswordEnv: "PGPASSWORD"},
+	"mysql":    {Binary: "mysql", User: "root", PasswordKeys: []string{"mysql-root-password", "mysql-password"}, PasswordEnv: "MYSQL_PWD"},
+	// mongosh has no such variable; the login script built by command reads
+	// this one instead.
+	"mongodb": {Binary: "mongosh", User: "root", PasswordKeys: []string{"mongodb-root-password"}, PasswordEnv: "SB_MONGODB_PASSWORD"},
+	"redis":   {Binary: "redis-cli", PasswordKeys: []string{"redis-password"}, PasswordEnv: "REDISCLI_AUTH"},
+}
+
+// command returns the arguments to connect to host:port. The password, if
+// any, is expected in the PasswordEnv environment variable.
+func (c serviceClient) command(host string, port int, withPassword bool, extra []string) []string {
+	var args []string
+	portArg := strconv.Itoa(port)
+	switch c.Binary {
+	case "psql":
+		args = []string{"-h", host, "-p", portArg, "-U", c.User}
+	case "mysql":
+		args = []string{"-h", host, "-P", portArg, "-u", c.User}
+	case "mongosh":
+		uri := fmt.Sprintf("%q", fmt.Sprintf("mongodb://%s:%d/admin", host, port))
+		if withPassword {
+			uri = fmt.Sprintf(`"mongodb://%s:" + encodeURIComponent(process.env.%s) + "@%s:%d/admin"`, c.User, c.PasswordEnv, host, port)
+		}
+		args = []string{"--nodb", "--eval", "db = connect(" + uri + ")"}
+		// Stay in the shell unless extra arguments say what to run.
+		if len(extra) == 0 {
+			args = append(args, "--shell")
+		}
+	case "redis-cli":
+		args = []string{"-h", host, "-p", portArg}
+	}
+	return append(args, extra...)
+}
+
+func svcConnect(cmd *cobra.Command, args []string) {
+	service, err := resolveService(connectService)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	client, ok := serviceClients[service.Type]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: connecting to %s services is not supported\n", service.Type)
+		os.Exit(1)
+	}
+	port := serviceDefaultPorts[service.Type]
+
+	forwarder, err := newServiceForwarder(service, []string{"localhost"}, []string{fmt.Sprintf(":%d", port)})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	secret, err := servicePassword(forwarder.cluster, service, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the credentials of %s: %v\n", service.Name, err)
+		os.Exit(1)
+	}
+
+	binary, lookErr := exec.LookPath(client.Binary)
+	if connectInCluster || lookErr != nil {
+		if !connectInCluster {
+			fmt.Fprintf(os.Stderr, "%s is not installed locally, using the one in the service's pod.\n", client.Binary)
+		}
+		os.Exit(connectInPod(forwarder, client, port, secret, args))
+	}
+	os.Exit(connectLocally(forwarder, client, binary, secret.Password, args))
+}
+
+// connectLocally runs the local client against a port-forward to the
+// service and returns its exit code.
+func connectLocally(forwarder *portForwarder, client serviceClient, binary, password string, extra []string) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready := make(chan int, 1)
+	forwarder.out = io.Discard
+	forwarder.onReady = func(ports []portforward.ForwardedPort) { ready <- int(ports[0].Local) }
+	failed := make(chan error, 1)
+	go func() { failed <- forwarder.run(ctx) }()
+
+	var localPort int
+	select {
+	case localPort = <-ready:
+	case err := <-failed:
+		fmt.Fprintf(os.Stderr, "Error forwarding to the service: %v\n", err)
+		return 1
+	case <-time.After(2 * time.Minute):
+		fmt.Fprintln(os.Stderr, "Error: timed out waiting for the service's pod")
+		return 1
+	}
+
+	// Ctrl-C is meant for the client, e.g. to cancel a query, so it must not
+	// stop the port-forward.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	command := exec.Command(binary, client.command("127.0.0.1", localPort, password != "", extra)...)
+	command.Env = os.Environ()
+	if password != "" {
+		command.Env = append(command.Env, client.PasswordEnv+"="+password)
+	}
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "Error running %s: %v\n", client.Binary, err)
+		return 1
+	}
+	return 0
+}
+
+// connectInPod runs the client inside the service's own pod and returns its
+// exit code. The exec request ends up in the API server's URL and logs, so
+// the password is not sent along; a shell in the pod takes it from the
+// container's environment or mounted secret instead.
+func connectInPod(forwarder *portForwarder, client serviceClient, port int, secret serviceSecret, extra []string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	pod, err := forwarder.waitForPod(ctx)
+	cancel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding the service's pod: %v\n", err)
+		return 1
+	}
+	container := appContainer(pod)
+
+	command := append([]string{client.Binary}, client.command("127.0.0.1", port, secret.Password != "", extra)...)
+	if secret.Password != "" {
+		password, ok := podSecretValue(pod, container, secret)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: the service's pod does not expose its password to %s, install %s locally to connect\n", container, client.Binary)
+			return 1
+		}
+		script := fmt.Sprintf(`%s=%s; export %s; exec "$@"`, client.PasswordEnv, password, client.PasswordEnv)
+		command = append([]string{"sh", "-c", script, "sh"}, command...)
+	}
+
+	err = forwarder.cluster.exec(context.Background(), execOptions{
+		Pod:       pod.Name,
+		Container: container,
+		Command:   command,
+		TTY:       term.IsTerminal(int(os.Stdin.Fd())),
+		Stdin:     os.Stdin,
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+	})
+	return exitCode(err)
+}
+
+// serviceSecret is the password of a service and where it is stored.
+type serviceSecret struct {
+	Name     string
+	Key      string
+	Password string
+}
+
+// servicePassword reads the password from the secret of the service's Helm
+// release. Services without a password secret get an empty password.
+func servicePassword(cluster *appCluster, service Service, client serviceClient) (serviceSecret, error) {
+	secrets, err := cluster.clientset.CoreV1().Secrets(cluster.namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", serviceInstanceLabel, service.Name),
+	})
+	if err != nil {
+		return serviceSecret{}, err
+	}
+	sort.Slice(secrets.Items, func(i, j int) bool { return secrets.Items[i].Name < secrets.Items[j].Name })
+	for _, key := range client.PasswordKeys {
+		for _, secret := range secrets.Items {
+			if value, ok := secret.Data[key]; ok {
+				return serviceSecret{Name: secret.Name, Key: key, Password: string(value)}, nil
+			}
+		}
+	}
+	return serviceSecret{}, nil
+}
+
+var shellVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// podSecretValue returns a shell expression that yields secret inside
+// container: the environment variable or mounted file it is made available
+// as.
+func podSecretValue(pod *corev1.Pod, container string, secret serviceSecret) (string, bool) {
+	var spec *corev1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == container {
+			spec = &pod.Spec.Containers[i]
+		}
+	}
+	if spec == nil {
+		return "", false
+	}
+
+	for _, env := range spec.Env {
+		ref := env.ValueFrom
+		if ref == nil || ref.SecretKeyRef == nil || !shellVariableName.MatchString(env.Name) {
+			continue
+		}
+		if ref.SecretKeyRef.Name == secret.Name && ref.SecretKeyRef.Key == secret.Key {
+			return fmt.Sprintf(`"$%s"`, env.Name), true
+		}
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Secret == nil || volume.Secret.SecretName != secret.Name {
+			continue
+		}
+		file := secret.Key
+		if len(volume.Secret.Items) > 0 {
+			file = ""
+			for _, item := range volume.Secret.Items {
+				if item.Key == secret.Key {
+					file = item.Path
+				}
+			}
+		}
+		if file == "" {
+			continue
+		}
+		for _, mount := range spec.VolumeMounts {
+			if mount.Name == volume.Name && mount.SubPath == "" {
+				return fmt.Sprintf(`"$(cat %s)"`, shellQuote(path.Join(mount.MountPath, file))), true
+			}
+		}
+	}
+	return "", false
+}
+
+// shellQuote quotes s for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func init() {
+	svcConnectCmd.Flags().StringVar(&connectService, "service", "", "Service name or UUID")
+	svcConnectCmd.Flags().BoolVar(&connectInCluster, "in-cluster", false, "Use the client in the service's pod even if one is installed locally")
+	servicesCmd.AddCommand(svcConnectCmd)
+}
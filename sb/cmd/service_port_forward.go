@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// serviceInstanceLabel selects the pods of a service. Services are installed
+// as Helm releases named after the service.
+const serviceInstanceLabel = "app.kubernetes.io/instance"
+
+// Ports the service types listen on.
+var serviceDefaultPorts = map[string]int{
+	"postgres": 5432,
+	"mysql":    3306,
+	"mongodb":  27017,
+	"redis":    6379,
+}
+
+var svcPortForwardCmd = &cobra.Command{
+	Use:   "port-forward [[LOCAL:]REMOTE...]",
+	Short: "Forward local ports to a service",
+	Long: `Forwards local ports to a service's pod, e.g. to reach a database:
+
+  sb-cli services port-forward --service mydb 5432
+
+Without ports, the default port of the service type is forwarded. The service
+must be attached to an app, whose cluster access is used.`,
+	Run: svcPortForward,
+}
+
+var (
+	portForwardService          string
+	portForwardServiceAddresses []string
+)
+
+func svcPortForward(cmd *cobra.Command, args []string) {
+	if err := validatePortPairs(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	service, err := resolveService(portForwardService)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		port, ok := serviceDefaultPorts[service.Type]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: no default port for %s services, pass one\n", service.Type)
+			os.Exit(1)
+		}
+		args = []string{strconv.Itoa(port)}
+	}
+
+	forwarder, err := newServiceForwarder(service, portForwardServiceAddresses, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := forwarder.run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newServiceForwarder connects to the cluster of the first app the service
+// is attached to.
+func newServiceForwarder(service Service, addresses, ports []string) (*portForwarder, error) {
+	if len(service.Apps) == 0 {
+		return nil, fmt.Errorf("service %s is not attached to an app, attach it with `sb-cli services attach`", service.Name)
+	}
+	cluster, err := connectApp(service.Apps[0].UUID)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the cluster: %w", err)
+	}
+	return &portForwarder{
+		cluster:   cluster,
+		selector:  fmt.Sprintf("%s=%s", serviceInstanceLabel, service.Name),
+		accept:    func(*corev1.Pod) bool { return true },
+		addresses: addresses,
+		ports:     ports,
+	}, nil
+}
+
+func init() {
+	svcPortForwardCmd.Flags().StringVar(&portForwardService, "service", "", "Service name or UUID")
+	svcPortForwardCmd.Flags().StringSliceVar(&portForwardServiceAddresses, "address", []string{"localhost"}, "Addresses to listen on, comma separated")
+	servicesCmd.AddCommand(svcPortForwardCmd)
+}
@@ -45,8 +45,7 @@ func fetchServices() ([]Service, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -92,6 +91,28 @@ func selectService(services []Service) Service {
 	return services[index]
 }
 
+// resolveService finds a service by name or UUID, prompting for one when
+// name is empty.
+func resolveService(name string) (Service, error) {
+	services, err := fetchServices()
+	if err != nil {
+		return Service{}, err
+	}
+	if name == "" {
+		service := selectService(services)
+		if service.UUID == "" {
+			return Service{}, fmt.Errorf("no service selected")
+		}
+		return service, nil
+	}
+	for _, service := range services {
+		if service.Name == name || service.UUID == name {
+			return service, nil
+		}
+	}
+	return Service{}, fmt.Errorf("service %q not found", name)
+}
+
 var servicesCmd = &cobra.Command{
 	Use:     "services",
 	Aliases: []string{"service", "svc"},
@@ -44,6 +44,18 @@ func readConfig(configFile string) (Config, error) {
 	return cfg, nil
 }
 
+// writeConfig writes cfg back to the configuration file.
+func writeConfig(configFile string, cfg Config) error {
+	configData, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %v", err)
+	}
+	if err := ioutil.WriteFile(configFile, configData, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
 func switchContext() error {
 	configFile := viper.ConfigFileUsed()
 	if configFile == "" {
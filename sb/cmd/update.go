@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/shapeblock/sb-cli/sb/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultUpdateFeed = "https://api.github.com/repos/shapeblock/sb-cli/releases"
+
+	updateChannelStable = "stable"
+	updateChannelBeta   = "beta"
+)
+
+// Release is one entry of the release feed. The feed uses the shape of the
+// GitHub releases API so GitHub can serve it directly.
+type Release struct {
+	TagName    string         `json:"tag_name"`
+	Prerelease bool           `json:"prerelease"`
+	Draft      bool           `json:"draft"`
+	Assets     []ReleaseAsset `json:"assets"`
+}
+
+type ReleaseAsset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
+func (r Release) asset(name string) (ReleaseAsset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// binaryAsset finds the build for this OS and architecture, either as a bare
+// binary or as a .tar.gz/.zip archive.
+func (r Release) binaryAsset() (ReleaseAsset, bool) {
+	goos := runtime.GOOS
+	if goos == "darwin" {
+		goos = "macos"
+	}
+	base := fmt.Sprintf("sb-cli-%s-%s", goos, runtime.GOARCH)
+	for _, suffix := range []string{"", ".exe", ".tar.gz", ".zip"} {
+		if asset, ok := r.asset(base + suffix); ok {
+			return asset, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update sb-cli to the latest release",
+	Long: `Downloads the latest release for this platform from the release feed, verifies
+its SHA256 checksum (and signature, when update-public-key is configured) and
+replaces the running binary. Development builds, whose version is not a
+release tag, are only replaced after confirming or with --force.`,
+	Run: selfUpdate,
+}
+
+var (
+	updateCheckOnly bool
+	updateChannel   string
+	updateFeed      string
+	updateForce     bool
+)
+
+func selfUpdate(cmd *cobra.Command, args []string) {
+	configFile := viper.ConfigFileUsed()
+	cfg, err := readConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if cmd.Flags().Changed("channel") || cmd.Flags().Changed("feed") {
+		if cmd.Flags().Changed("channel") {
+			if updateChannel != updateChannelStable && updateChannel != updateChannelBeta {
+				fmt.Fprintf(os.Stderr, "Invalid channel %q, use %s or %s.\n", updateChannel, updateChannelStable, updateChannelBeta)
+				os.Exit(1)
+			}
+			cfg.UpdateChannel = updateChannel
+		}
+		if cmd.Flags().Changed("feed") {
+			cfg.UpdateFeed = updateFeed
+		}
+		if err := writeConfig(configFile, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving update settings: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	channel := cfg.UpdateChannel
+	if channel == "" {
+		channel = updateChannelStable
+	}
+	feed := cfg.UpdateFeed
+	if feed == "" {
+		feed = defaultUpdateFeed
+	}
+
+	release, err := latestRelease(feed, channel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error checking for updates: %v\n", err)
+		os.Exit(1)
+	}
+
+	current := config.GetVersion()
+	_, released := parseVersion(current)
+	if released && compareVersions(release.TagName, current) <= 0 {
+		fmt.Printf("sb-cli %s is up to date (%s channel).\n", current, channel)
+		return
+	}
+	fmt.Printf("sb-cli %s is available (current: %s, %s channel).\n", release.TagName, current, channel)
+	if updateCheckOnly {
+		return
+	}
+
+	// A development build cannot be compared to releases, so it is not
+	// necessarily older.
+	if !released && !updateForce {
+		confirmationPrompt := promptui.Prompt{
+			Label:     fmt.Sprintf("sb-cli %s is a development build, replace it with %s", current, release.TagName),
+			IsConfirm: true,
+		}
+		if _, err := confirmationPrompt.Run(); err != nil {
+			fmt.Println("Update cancelled, use --force to replace a development build.")
+			os.Exit(1)
+		}
+	}
+
+	if err := installRelease(release, cfg.UpdatePublicKey); err != nil {
+		fmt.Fprintf(os.Stderr, "Update failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated sb-cli to %s.\n", release.TagName)
+}
+
+// latestRelease returns the newest published release on channel. The stable
+// channel skips pre-releases.
+func latestRelease(feed, channel string) (Release, error) {
+	req, err := http.NewRequest("GET", feed, nil)
+	if err != nil {
+		return Release{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return Release{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Release{}, fmt.Errorf("release feed returned %s", resp.Status)
+	}
+
+	var releases []Release
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return Release{}, fmt.Errorf("failed to decode release feed: %w", err)
+	}
+
+	var latest Release
+	for _, release := range releases {
+		if release.Draft || (release.Prerelease && channel != updateChannelBeta) {
+			continue
+		}
+		if _, ok := parseVersion(release.TagName); !ok {
+			continue
+		}
+		if _, ok := release.binaryAsset(); !ok {
+			continue
+		}
+		if latest.TagName == "" || compareVersions(release.TagName, latest.TagName) > 0 {
+			latest = release
+		}
+	}
+	if latest.TagName == "" {
+		return Release{}, fmt.Errorf("no %s release found for %s/%s", channel, runtime.GOOS, runtime.GOARCH)
+	}
+	return latest, nil
+}
+
+func installRelease(release Release, publicKey string) error {
+	asset, _ := release.binaryAsset()
+
+	data, err := download(asset.DownloadURL)
+	if err != nil {
+		return err
+	}
+
+	checksumAsset, ok := release.asset(asset.Name + ".sha256")
+	if !ok {
+		return fmt.Errorf("release %s has no checksum for %s", release.TagName, asset.Name)
+	}
+	checksumFile, err := download(checksumAsset.DownloadURL)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksum(data, checksumFile); err != nil {
+		return err
+	}
+
+	if publicKey != "" {
+		sigAsset, ok := release.asset(asset.Name + ".sig")
+		if !ok {
+			return fmt.Errorf("release %s has no signature for %s", release.TagName, asset.Name)
+		}
+		sig, err := download(sigAsset.DownloadURL)
+		if err != nil {
+			return err
+		}
+		if err := verifySignature(data, sig, publicKey); err != nil {
+			return err
+		}
+	}
+
+	binary, err := extractBinary(asset.Name, data)
+	if err != nil {
+		return err
+	}
+	return replaceExecutable(binary)
+}
+
+func download(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s failed: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifyChecksum compares data against a checksum file in sha256sum format.
+func verifyChecksum(data, checksumFile []byte) error {
+	fields := strings.Fields(string(checksumFile))
+	if len(fields) == 0 {
+		return fmt.Errorf("checksum file is empty")
+	}
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %x", fields[0], sum)
+	}
+	return nil
+}
+
+// verifySignature checks a base64 encoded ed25519 signature of data.
+func verifySignature(data, sig []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("update-public-key is not a base64 encoded ed25519 key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, signature) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// extractBinary returns the sb-cli executable from a downloaded asset.
+func extractBinary(name string, data []byte) ([]byte, error) {
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(gz)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if header.Typeflag == tar.TypeReg && strings.HasPrefix(filepath.Base(header.Name), "sb-cli") {
+				return io.ReadAll(tr)
+			}
+		}
+		return nil, fmt.Errorf("no sb-cli binary in %s", name)
+	case strings.HasSuffix(name, ".zip"):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range zr.File {
+			if !file.FileInfo().IsDir() && strings.HasPrefix(filepath.Base(file.Name), "sb-cli") {
+				rc, err := file.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(rc)
+			}
+		}
+		return nil, fmt.Errorf("no sb-cli binary in %s", name)
+	default:
+		return data, nil
+	}
+}
+
+// replaceExecutable writes binary next to the running executable and renames
+// it into place so the swap is atomic.
+func replaceExecutable(binary []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+	// Windows cannot overwrite a running executable, but it can rename it.
+	return replaceFile(exe, binary, runtime.GOOS == "windows")
+}
+
+// renameFile is os.Rename, replaced in tests to simulate failures.
+var renameFile = os.Rename
+
+// replaceFile replaces path with binary. With moveAside, path is first
+// renamed to path.old, and moved back if binary cannot be put in its place.
+func replaceFile(path string, binary []byte, moveAside bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".sb-cli-update-*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", filepath.Dir(path), err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	if !moveAside {
+		return renameFile(tmp.Name(), path)
+	}
+	old := path + ".old"
+	os.Remove(old)
+	if err := renameFile(path, old); err != nil {
+		return err
+	}
+	if err := renameFile(tmp.Name(), path); err != nil {
+		if restoreErr := renameFile(old, path); restoreErr != nil {
+			return fmt.Errorf("%w; restoring the previous binary from %s also failed: %v", err, old, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().BoolVar(&updateCheckOnly, "check", false, "Only report whether an update is available")
+	updateCmd.Flags().StringVar(&updateChannel, "channel", "", "Release channel to follow and save in the config (stable or beta)")
+	updateCmd.Flags().StringVar(&updateFeed, "feed", "", "Release feed URL to use and save in the config")
+	updateCmd.Flags().BoolVar(&updateForce, "force", false, "Replace a development build without asking")
+}
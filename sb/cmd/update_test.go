@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("sb-cli binary")
+	sum := sha256.Sum256(data)
+	good := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  string
+	}{
+		{"sha256sum format", good + "  sb-cli-linux-amd64\n", ""},
+		{"upper case", strings.ToUpper(good), ""},
+		{"mismatch", strings.Repeat("0", 64) + "  sb-cli-linux-amd64\n", "checksum mismatch"},
+		{"checksum of other data", hex.EncodeToString(make([]byte, 32)), "checksum mismatch"},
+		{"empty", "", "checksum file is empty"},
+		{"whitespace only", " \n", "checksum file is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, verifyChecksum(data, []byte(tt.checksum)), tt.wantErr)
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	data := []byte("sb-cli binary")
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(publicKey)
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, data))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		sig     string
+		key     string
+		wantErr string
+	}{
+		{"valid", data, sig, key, ""},
+		{"trailing newline", data, sig + "\n", key, ""},
+		{"missing signature", data, "", key, "signature verification failed"},
+		{"not base64", data, "not a signature!", key, "invalid signature encoding"},
+		{"truncated", data, base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, data)[:32]), key, "signature verification failed"},
+		{"tampered data", []byte("sb-cli binarY"), sig, key, "signature verification failed"},
+		{"other key", data, sig, base64.StdEncoding.EncodeToString(otherPublicKey), "signature verification failed"},
+		{"key not base64", data, sig, "not a key!", "not a base64 encoded ed25519 key"},
+		{"key too short", data, sig, base64.StdEncoding.EncodeToString(publicKey[:16]), "not a base64 encoded ed25519 key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, verifySignature(tt.data, []byte(tt.sig), tt.key), tt.wantErr)
+		})
+	}
+}
+
+// archiveEntry is a file or, with dir set, a directory in a test archive.
+type archiveEntry struct {
+	name string
+	body string
+	dir  bool
+}
+
+func tarGz(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0755, Size: int64(len(entry.body)), Typeflag: tar.TypeReg}
+		if entry.dir {
+			header.Typeflag, header.Size = tar.TypeDir, 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		name := entry.name
+		if entry.dir {
+			name += "/"
+		}
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		asset   string
+		data    []byte
+		want    string
+		wantErr string
+	}{
+		{"bare binary", "sb-cli-linux-amd64", []byte("binary"), "binary", ""},
+		{"tar.gz", "sb-cli-linux-amd64.tar.gz", tarGz(t,
+			archiveEntry{name: "README.md", body: "docs"},
+			archiveEntry{name: "sb-cli", body: "binary"},
+		), "binary", ""},
+		{"tar.gz in a directory", "sb-cli-linux-amd64.tar.gz", tarGz(t,
+			archiveEntry{name: "sb-cli-linux-amd64", dir: true},
+			archiveEntry{name: "sb-cli-linux-amd64/sb-cli", body: "binary"},
+		), "binary", ""},
+		{"tar.gz without binary", "sb-cli-linux-amd64.tar.gz", tarGz(t,
+			archiveEntry{name: "README.md", body: "docs"},
+		), "", "no sb-cli binary"},
+		{"tar.gz with only a directory", "sb-cli-linux-amd64.tar.gz", tarGz(t,
+			archiveEntry{name: "sb-cli", dir: true},
+		), "", "no sb-cli binary"},
+		{"tar.gz not gzipped", "sb-cli-linux-amd64.tar.gz", []byte("not a gzip archive"), "", "gzip: invalid header"},
+		{"tar.gz truncated", "sb-cli-linux-amd64.tar.gz", tarGz(t,
+			archiveEntry{name: "sb-cli", body: "binary"},
+		)[:20], "", "unexpected EOF"},
+		{"zip", "sb-cli-windows-amd64.zip", zipArchive(t,
+			archiveEntry{name: "docs", dir: true},
+			archiveEntry{name: "docs/README.md", body: "docs"},
+			archiveEntry{name: "sb-cli.exe", body: "binary"},
+		), "binary", ""},
+		{"zip without binary", "sb-cli-windows-amd64.zip", zipArchive(t,
+			archiveEntry{name: "README.md", body: "docs"},
+		), "", "no sb-cli binary"},
+		{"zip with only a directory", "sb-cli-windows-amd64.zip", zipArchive(t,
+			archiveEntry{name: "sb-cli", dir: true},
+		), "", "no sb-cli binary"},
+		{"zip corrupt", "sb-cli-windows-amd64.zip", []byte("binary"), "", "zip: not a valid zip file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractBinary(tt.asset, tt.data)
+			checkError(t, err, tt.wantErr)
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		moveAside bool
+		// failRename makes renaming the new binary into place fail.
+		failRename bool
+		want       string
+		wantErr    string
+	}{
+		{"replace", false, false, "new", ""},
+		{"move aside", true, false, "new", ""},
+		{"replace fails", false, true, "old", "rename failed"},
+		{"move aside restores on failure", true, true, "old", "rename failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exe := filepath.Join(t.TempDir(), "sb-cli")
+			if err := os.WriteFile(exe, []byte("old"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.failRename {
+				renameFile = func(from, to string) error {
+					if strings.Contains(filepath.Base(from), ".sb-cli-update-") {
+						return errors.New("rename failed")
+					}
+					return os.Rename(from, to)
+				}
+				t.Cleanup(func() { renameFile = os.Rename })
+			}
+
+			checkError(t, replaceFile(exe, []byte("new"), tt.moveAside), tt.wantErr)
+			got, err := os.ReadFile(exe)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("%s contains %q, want %q", exe, got, tt.want)
+			}
+			leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(exe), ".sb-cli-update-*"))
+			if len(leftovers) > 0 {
+				t.Errorf("temporary files left behind: %v", leftovers)
+			}
+		})
+	}
+}
+
+// checkError fails t unless err contains wantErr, or is nil if wantErr is
+// empty.
+func checkError(t *testing.T, err error, wantErr string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("got error %v, want one containing %q", err, wantErr)
+	}
+}
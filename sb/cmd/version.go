@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/shapeblock/sb-cli/sb/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// compatWarningInterval limits how often other commands warn about an
+// unsupported server version.
+const compatWarningInterval = 24 * time.Hour
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the client and server versions",
+	Run:   showVersion,
+}
+
+func showVersion(cmd *cobra.Command, args []string) {
+	fmt.Println("Client:")
+	fmt.Printf("  Version:    %s\n", config.GetVersion())
+	fmt.Printf("  Commit:     %s\n", config.GetCommit())
+	fmt.Printf("  Built:      %s\n", config.GetBuildDate())
+	fmt.Printf("  Go version: %s\n", runtime.Version())
+	fmt.Printf("  OS/Arch:    %s/%s\n", runtime.GOOS, runtime.GOARCH)
+
+	cfg, err := readConfig(viper.ConfigFileUsed())
+	if err != nil {
+		return
+	}
+	contextInfo, ok := cfg.Contexts[cfg.CurrentContext]
+	if !ok {
+		fmt.Println("\nNot logged in, server version unknown.")
+		return
+	}
+
+	fmt.Println("\nServer:")
+	fmt.Printf("  Context:    %s\n", cfg.CurrentContext)
+	caps, err := discoverCapabilities(contextInfo.Endpoint, contextInfo.Token)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching server version: %v\n", err)
+		os.Exit(1)
+	}
+	contextInfo.Capabilities = &caps
+	cfg.Contexts[cfg.CurrentContext] = contextInfo
+	if err := writeConfig(viper.ConfigFileUsed(), cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save server capabilities: %v\n", err)
+	}
+
+	serverVersion := caps.Version
+	if serverVersion == "" {
+		serverVersion = "unknown"
+	}
+	fmt.Printf("  Version:    %s\n", serverVersion)
+	fmt.Printf("  Type:       %s\n", caps.Server)
+
+	compatible, reason := checkCompatibility(caps)
+	if compatible {
+		fmt.Printf("  Compatible: yes (%s)\n", reason)
+	} else {
+		fmt.Printf("  Compatible: no (%s)\n", reason)
+	}
+}
+
+// warnIfIncompatible prints a warning when the current server is outside the
+// supported version range, at most once per compatWarningInterval. It runs
+// before every command, so it works from the cached capabilities and only
+// refreshes them once they are stale, at most once per capabilitiesTTL and
+// within capabilitiesRefreshTimeout.
+func warnIfIncompatible() {
+	configFile := viper.ConfigFileUsed()
+	cfg, err := readConfig(configFile)
+	if err != nil {
+		return
+	}
+	contextInfo, ok := cfg.Contexts[cfg.CurrentContext]
+	if !ok {
+		return
+	}
+	if capabilitiesRefreshDue(contextInfo) {
+		contextInfo.CapabilitiesTriedAt = time.Now().Format(time.RFC3339)
+		ctx, cancel := context.WithTimeout(context.Background(), capabilitiesRefreshTimeout)
+		caps, err := discoverCapabilitiesContext(ctx, contextInfo.Endpoint, contextInfo.Token)
+		cancel()
+		if err == nil {
+			contextInfo.Capabilities = &caps
+		}
+		cfg.Contexts[cfg.CurrentContext] = contextInfo
+		writeConfig(configFile, cfg)
+	}
+	if contextInfo.Capabilities == nil {
+		return
+	}
+	if warnedAt, err := time.Parse(time.RFC3339, contextInfo.CompatWarnedAt); err == nil && time.Since(warnedAt) < compatWarningInterval {
+		return
+	}
+
+	compatible, reason := checkCompatibility(*contextInfo.Capabilities)
+	if compatible {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s. Run 'sb-cli version' for details.\n", reason)
+
+	contextInfo.CompatWarnedAt = time.Now().Format(time.RFC3339)
+	cfg.Contexts[cfg.CurrentContext] = contextInfo
+	writeConfig(configFile, cfg)
+}
+
+// capabilitiesRefreshDue reports whether the cached capabilities of
+// contextInfo are missing or stale and no refresh was tried in the last
+// capabilitiesTTL, so an unreachable server is not retried on every command.
+func capabilitiesRefreshDue(contextInfo ContextInfo) bool {
+	if contextInfo.Endpoint == "" || (contextInfo.Capabilities != nil && !contextInfo.Capabilities.stale()) {
+		return false
+	}
+	triedAt, err := time.Parse(time.RFC3339, contextInfo.CapabilitiesTriedAt)
+	return err != nil || time.Since(triedAt) > capabilitiesTTL
+}
+
+// skipCompatWarning reports whether cmd either checks compatibility itself or
+// runs before there is a context to check.
+func skipCompatWarning(cmd *cobra.Command) bool {
+	switch cmd {
+	case rootCmd, versionCmd, doctorCmd, loginCmd, logoutCmd, registerCmd, switchCmd, updateCmd:
+		return true
+	}
+	return cmd.Name() == "help"
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
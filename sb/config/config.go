@@ -11,13 +11,27 @@ import (
 	"github.com/spf13/viper"
 )
 
-var version = "master"
+var (
+	version = "master"
+	commit  = "unknown"
+	date    = "unknown"
+)
 
 // GetVersion returns version of PusherCLI, set in ldflags.
 func GetVersion() string {
 	return version
 }
 
+// GetCommit returns the git commit the binary was built from, set in ldflags.
+func GetCommit() string {
+	return commit
+}
+
+// GetBuildDate returns when the binary was built, set in ldflags.
+func GetBuildDate() string {
+	return date
+}
+
 func getUserHomeDir() string {
 	usr, err := user.Current()
 	if err != nil {